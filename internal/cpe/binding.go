@@ -0,0 +1,236 @@
+package cpe
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseFormattedString は形式化文字列 (cpe:2.3:part:vendor:product:...) を解析します
+func ParseFormattedString(s string) (Name, error) {
+	fields := splitFS(s)
+	if len(fields) != int(attributeCount)+2 || fields[0] != "cpe" || fields[1] != "2.3" {
+		return Name{}, fmt.Errorf("invalid CPE formatted string %q: expected 13 components", s)
+	}
+
+	var n Name
+	for i, field := range fields[2:] {
+		v, err := unbindValueFS(field)
+		if err != nil {
+			return Name{}, fmt.Errorf("invalid CPE formatted string %q: %s: %w", s, Attribute(i), err)
+		}
+		n.values[i] = v
+	}
+
+	if err := n.validatePart(); err != nil {
+		return Name{}, fmt.Errorf("invalid CPE formatted string %q: %w", s, err)
+	}
+
+	return n, nil
+}
+
+// ParseURI はURIバインディング (cpe:/part:vendor:product:version:update:edition:language) を解析します。
+// edition が '~' で始まる場合は、まとめられた拡張属性 (sw_edition など) として展開します。
+func ParseURI(s string) (Name, error) {
+	components := strings.Split(strings.TrimPrefix(s, "cpe:/"), ":")
+	if !strings.HasPrefix(s, "cpe:/") || len(components) > 7 {
+		return Name{}, fmt.Errorf("invalid CPE URI %q", s)
+	}
+
+	var n Name
+	for i, component := range components {
+		attr := Attribute(i)
+
+		if attr == Edition && strings.HasPrefix(component, "~") {
+			if err := n.unpackEdition(component); err != nil {
+				return Name{}, fmt.Errorf("invalid CPE URI %q: %w", s, err)
+			}
+			continue
+		}
+
+		v, err := decodeURIValue(component)
+		if err != nil {
+			return Name{}, fmt.Errorf("invalid CPE URI %q: %s: %w", s, attr, err)
+		}
+		n.values[attr] = v
+	}
+
+	if err := n.validatePart(); err != nil {
+		return Name{}, fmt.Errorf("invalid CPE URI %q: %w", s, err)
+	}
+
+	return n, nil
+}
+
+func (n *Name) validatePart() error {
+	part := n.values[Part]
+	if part.Kind == Any {
+		return nil
+	}
+
+	switch part.wfn {
+	case "a", "o", "h":
+		return nil
+	default:
+		return fmt.Errorf("invalid part %q", bindValueFS(part))
+	}
+}
+
+// unpackEdition は "~edition~sw_edition~target_sw~target_hw~other" を展開します
+func (n *Name) unpackEdition(packed string) error {
+	parts := strings.Split(packed, "~")
+	if len(parts) != 6 {
+		return fmt.Errorf("invalid packed edition %q", packed)
+	}
+
+	for i, attr := range []Attribute{Edition, SWEdition, TargetSW, TargetHW, Other} {
+		v, err := decodeURIValue(parts[i+1])
+		if err != nil {
+			return fmt.Errorf("%s: %w", attr, err)
+		}
+		n.values[attr] = v
+	}
+
+	return nil
+}
+
+// splitFS はエスケープされていない ':' で分割します (エスケープはそのまま残します)
+func splitFS(s string) []string {
+	var fields []string
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ':':
+			fields = append(fields, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(fields, s[start:])
+}
+
+// unbindValueFS は形式化文字列の値をWFNの値に変換します
+func unbindValueFS(s string) (Value, error) {
+	switch s {
+	case "*":
+		return AnyValue, nil
+	case "-":
+		return NAValue, nil
+	case "":
+		return Value{}, fmt.Errorf("empty value")
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case isAlphanumeric(c):
+			b.WriteByte(c)
+		case c == '\\':
+			if i+1 >= len(s) {
+				return Value{}, fmt.Errorf("unterminated escape in %q", s)
+			}
+			i++
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		case c == '*':
+			// '*' は先頭か末尾にのみ置ける
+			if i != 0 && i != len(s)-1 {
+				return Value{}, fmt.Errorf("embedded '*' in %q", s)
+			}
+			b.WriteByte(c)
+		case c == '?':
+			// '?' は先頭か末尾の連続した並びにのみ置ける
+			if strings.Trim(s[:i], "?") != "" && strings.Trim(s[i:], "?") != "" {
+				return Value{}, fmt.Errorf("embedded '?' in %q", s)
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+
+	return Value{Kind: Literal, wfn: b.String()}, nil
+}
+
+// bindValueFS はWFNの値を形式化文字列の値に変換します
+func bindValueFS(v Value) string {
+	switch v.Kind {
+	case Any:
+		return "*"
+	case NA:
+		return "-"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(v.wfn); i++ {
+		c := v.wfn[i]
+		if c == '\\' && i+1 < len(v.wfn) {
+			i++
+			c = v.wfn[i]
+			// '.', '-', '_' は形式化文字列ではエスケープしない
+			if c != '.' && c != '-' && c != '_' {
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// decodeURIValue はURIの値をWFNの値に変換します。
+// %01 と %02 はそれぞれワイルドカードの '?' と '*' を表します。
+func decodeURIValue(s string) (Value, error) {
+	switch s {
+	case "":
+		return AnyValue, nil
+	case "-":
+		return NAValue, nil
+	}
+
+	s = strings.ToLower(s)
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c == '%' {
+			if i+2 >= len(s) {
+				return Value{}, fmt.Errorf("invalid percent-encoding in %q", s)
+			}
+
+			form := s[i : i+3]
+			i += 2
+
+			switch form {
+			case "%01":
+				b.WriteByte('?')
+				continue
+			case "%02":
+				if i-2 != 0 && i != len(s)-1 {
+					return Value{}, fmt.Errorf("embedded '*' in %q", s)
+				}
+				b.WriteByte('*')
+				continue
+			}
+
+			decoded, err := strconv.ParseUint(form[1:], 16, 8)
+			if err != nil {
+				return Value{}, fmt.Errorf("invalid percent-encoding %q in %q", form, s)
+			}
+			c = byte(decoded)
+		}
+
+		if !isAlphanumeric(c) {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+
+	return Value{Kind: Literal, wfn: b.String()}, nil
+}
@@ -0,0 +1,160 @@
+// Package cpe はCPE 2.3の名前 (WFN) の解析と照合を行います。
+// 形式化文字列 (cpe:2.3:...) とURI (cpe:/...) のバインディングに対応し、
+// 照合はNISTIR 7696の名前照合の仕様に従います。
+package cpe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Attribute はWFNの属性です
+type Attribute int
+
+const (
+	Part Attribute = iota
+	Vendor
+	Product
+	Version
+	Update
+	Edition
+	Language
+	SWEdition
+	TargetSW
+	TargetHW
+	Other
+
+	attributeCount
+)
+
+var attributeNames = [attributeCount]string{
+	"part", "vendor", "product", "version", "update", "edition",
+	"language", "sw_edition", "target_sw", "target_hw", "other",
+}
+
+func (a Attribute) String() string {
+	if a < 0 || a >= attributeCount {
+		return fmt.Sprintf("Attribute(%d)", int(a))
+	}
+	return attributeNames[a]
+}
+
+// ValueKind は属性値の種類です
+type ValueKind int
+
+const (
+	// Any は任意の値 (論理値 ANY) です
+	Any ValueKind = iota
+	// NA は値が存在しないこと (論理値 NA) を表します
+	NA
+	// Literal は文字列の値です
+	Literal
+)
+
+// Value はWFNの属性値です。
+// 文字列の値はWFNの内部表現で保持され、英数字と '_' 以外の文字は '\' でクォートされます。
+// クォートされていない '*' と '?' はワイルドカードです。
+type Value struct {
+	Kind ValueKind
+	wfn  string
+}
+
+var (
+	AnyValue = Value{Kind: Any}
+	NAValue  = Value{Kind: NA}
+)
+
+// LiteralValue はワイルドカードを含まない文字列から属性値を作ります
+func LiteralValue(s string) Value {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if !isAlphanumeric(s[i]) {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return Value{Kind: Literal, wfn: b.String()}
+}
+
+// WFN はWFN形式の内部表現を返します
+func (v Value) WFN() string {
+	return v.wfn
+}
+
+// Unquoted はクォートを取り除いた値を返します。ANY と NA の場合は空文字列を返します。
+func (v Value) Unquoted() string {
+	if v.Kind != Literal {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(v.wfn); i++ {
+		if v.wfn[i] == '\\' && i+1 < len(v.wfn) {
+			i++
+		}
+		b.WriteByte(v.wfn[i])
+	}
+	return b.String()
+}
+
+// HasWildcards はクォートされていない '*' または '?' を含むかどうかを返します
+func (v Value) HasWildcards() bool {
+	if v.Kind != Literal {
+		return false
+	}
+
+	for i := 0; i < len(v.wfn); i++ {
+		switch v.wfn[i] {
+		case '\\':
+			i++
+		case '*', '?':
+			return true
+		}
+	}
+	return false
+}
+
+// Name はCPE名 (WFN) です
+type Name struct {
+	values [attributeCount]Value
+}
+
+// Get は属性の値を返します
+func (n Name) Get(a Attribute) Value {
+	return n.values[a]
+}
+
+// Set は属性の値を設定した新しい名前を返します
+func (n Name) Set(a Attribute, v Value) Name {
+	n.values[a] = v
+	return n
+}
+
+// Parse は形式化文字列 (cpe:2.3:...) またはURI (cpe:/...) のCPE名を解析します
+func Parse(s string) (Name, error) {
+	switch {
+	case strings.HasPrefix(s, "cpe:2.3:"):
+		return ParseFormattedString(s)
+	case strings.HasPrefix(s, "cpe:/"):
+		return ParseURI(s)
+	default:
+		return Name{}, fmt.Errorf("invalid CPE name %q: unknown binding", s)
+	}
+}
+
+// String は形式化文字列 (cpe:2.3:...) にバインドします
+func (n Name) String() string {
+	var b strings.Builder
+	b.WriteString("cpe:2.3")
+
+	for _, v := range n.values {
+		b.WriteByte(':')
+		b.WriteString(bindValueFS(v))
+	}
+
+	return b.String()
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
@@ -0,0 +1,191 @@
+package cpe
+
+import "testing"
+
+func TestParseFormattedString(t *testing.T) {
+	tests := []struct {
+		name string
+		want map[Attribute]Value
+	}{
+		{
+			// NISTIR 7695 6.2.3 の例
+			name: `cpe:2.3:a:microsoft:internet_explorer:8.0.6001:beta:*:*:*:*:*:*`,
+			want: map[Attribute]Value{
+				Part:    {Kind: Literal, wfn: `a`},
+				Vendor:  {Kind: Literal, wfn: `microsoft`},
+				Product: {Kind: Literal, wfn: `internet_explorer`},
+				Version: {Kind: Literal, wfn: `8\.0\.6001`},
+				Update:  {Kind: Literal, wfn: `beta`},
+				Edition: AnyValue,
+			},
+		},
+		{
+			name: `cpe:2.3:a:microsoft:internet_explorer:8.*:sp?:*:*:*:*:*:*`,
+			want: map[Attribute]Value{
+				Version: {Kind: Literal, wfn: `8\.*`},
+				Update:  {Kind: Literal, wfn: `sp?`},
+			},
+		},
+		{
+			name: `cpe:2.3:a:hp:insight_diagnostics:7.4.0.1570:-:*:*:online:win2003:x64:*`,
+			want: map[Attribute]Value{
+				Update:    NAValue,
+				SWEdition: {Kind: Literal, wfn: `online`},
+				TargetSW:  {Kind: Literal, wfn: `win2003`},
+				TargetHW:  {Kind: Literal, wfn: `x64`},
+				Other:     AnyValue,
+			},
+		},
+		{
+			// クォートされた文字 (':' を含む) とワイルドカードではない '*'
+			name: `cpe:2.3:a:foo\\bar:big\$money\:2010:\*:*:*:*:special:ipod_touch:80gb:*`,
+			want: map[Attribute]Value{
+				Vendor:  {Kind: Literal, wfn: `foo\\bar`},
+				Product: {Kind: Literal, wfn: `big\$money\:2010`},
+				Version: {Kind: Literal, wfn: `\*`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		name, err := ParseFormattedString(tt.name)
+		if err != nil {
+			t.Errorf("ParseFormattedString(%q) error = %v", tt.name, err)
+			continue
+		}
+		for attr, want := range tt.want {
+			if got := name.Get(attr); got != want {
+				t.Errorf("ParseFormattedString(%q).Get(%s) = %+v, want %+v", tt.name, attr, got, want)
+			}
+		}
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want map[Attribute]Value
+	}{
+		{
+			uri: `cpe:/a:microsoft:internet_explorer:8.0.6001:beta`,
+			want: map[Attribute]Value{
+				Version:  {Kind: Literal, wfn: `8\.0\.6001`},
+				Update:   {Kind: Literal, wfn: `beta`},
+				Edition:  AnyValue,
+				Language: AnyValue,
+			},
+		},
+		{
+			// %01 と %02 はそれぞれワイルドカードの '?' と '*'
+			uri: `cpe:/a:microsoft:internet_explorer:8.%02:sp%01`,
+			want: map[Attribute]Value{
+				Version: {Kind: Literal, wfn: `8\.*`},
+				Update:  {Kind: Literal, wfn: `sp?`},
+			},
+		},
+		{
+			// NISTIR 7695 6.1.3 の例 (edition にまとめられた拡張属性)
+			uri: `cpe:/a:hp:insight_diagnostics:7.4.0.1570::~~online~win2003~x64~`,
+			want: map[Attribute]Value{
+				Update:    AnyValue,
+				Edition:   AnyValue,
+				SWEdition: {Kind: Literal, wfn: `online`},
+				TargetSW:  {Kind: Literal, wfn: `win2003`},
+				TargetHW:  {Kind: Literal, wfn: `x64`},
+				Other:     AnyValue,
+			},
+		},
+		{
+			// 大文字はURIでは小文字として扱い、パーセントエンコードされた文字はクォートする
+			uri: `cpe:/a:Foo%5cbar:big%24money_2010:-:-:~-~special~ipod_touch~80gb~`,
+			want: map[Attribute]Value{
+				Vendor:    {Kind: Literal, wfn: `foo\\bar`},
+				Product:   {Kind: Literal, wfn: `big\$money_2010`},
+				Version:   NAValue,
+				Update:    NAValue,
+				Edition:   NAValue,
+				SWEdition: {Kind: Literal, wfn: `special`},
+				TargetSW:  {Kind: Literal, wfn: `ipod_touch`},
+				TargetHW:  {Kind: Literal, wfn: `80gb`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		name, err := ParseURI(tt.uri)
+		if err != nil {
+			t.Errorf("ParseURI(%q) error = %v", tt.uri, err)
+			continue
+		}
+		for attr, want := range tt.want {
+			if got := name.Get(attr); got != want {
+				t.Errorf("ParseURI(%q).Get(%s) = %+v, want %+v", tt.uri, attr, got, want)
+			}
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{
+		`cpe:2.3:a:microsoft:internet_explorer:8.0.6001`,
+		`cpe:2.3:x:microsoft:internet_explorer:*:*:*:*:*:*:*:*`,
+		`cpe:2.3:a:microsoft:internet*explorer:*:*:*:*:*:*:*:*`,
+		`cpe:2.3:a:microsoft:in?ternet_explorer:*:*:*:*:*:*:*:*`,
+		`cpe:2.3:a:microsoft::*:*:*:*:*:*:*:*`,
+		`cpe:2.3:a:microsoft:internet_explorer:8.0\`,
+		`cpe:/a:microsoft:internet_explorer:8.%020`,
+		`cpe:/a:microsoft:internet_explorer:8.0:%zz`,
+		`cpe:/a:microsoft:internet_explorer:8.0::~online~win2003`,
+		`cpe:/a:microsoft:internet_explorer:8.0:beta:-:en:extra`,
+		`microsoft:internet_explorer`,
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`cpe:2.3:a:microsoft:internet_explorer:8.0.6001:beta:*:*:*:*:*:*`, `cpe:2.3:a:microsoft:internet_explorer:8.0.6001:beta:*:*:*:*:*:*`},
+		{`cpe:2.3:a:foo\\bar:big\$money\:2010:*:-:*:*:*:*:*:*`, `cpe:2.3:a:foo\\bar:big\$money\:2010:*:-:*:*:*:*:*:*`},
+		// '.', '-', '_' は形式化文字列ではクォートしない
+		{`cpe:/a:hp:insight-diagnostics:7.4.0.1570::~~online~win2003~x64~`, `cpe:2.3:a:hp:insight-diagnostics:7.4.0.1570:*:*:*:online:win2003:x64:*`},
+		{`cpe:/a:microsoft:internet_explorer:8.%02:sp%01`, `cpe:2.3:a:microsoft:internet_explorer:8.*:sp?:*:*:*:*:*:*`},
+	}
+
+	for _, tt := range tests {
+		name, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.in, err)
+		}
+		if got := name.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	v := LiteralValue("8.0:beta*")
+	if got, want := v.WFN(), `8\.0\:beta\*`; got != want {
+		t.Errorf("LiteralValue().WFN() = %q, want %q", got, want)
+	}
+	if got, want := v.Unquoted(), "8.0:beta*"; got != want {
+		t.Errorf("LiteralValue().Unquoted() = %q, want %q", got, want)
+	}
+	if v.HasWildcards() {
+		t.Errorf("LiteralValue().HasWildcards() = true, want false")
+	}
+
+	name, err := Parse(`cpe:2.3:a:vendor:product:1.*:?beta:\*:*:*:*:*:*`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for attr, want := range map[Attribute]bool{Version: true, Update: true, Edition: false, Language: false} {
+		if got := name.Get(attr).HasWildcards(); got != want {
+			t.Errorf("Get(%s).HasWildcards() = %v, want %v", attr, got, want)
+		}
+	}
+}
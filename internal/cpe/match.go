@@ -0,0 +1,203 @@
+package cpe
+
+import "strings"
+
+// Relation はNISTIR 7696で定義される、source から見た target との関係です
+type Relation int
+
+const (
+	// Disjoint は共通するCPE名が存在しないことを表します
+	Disjoint Relation = iota
+	// Subset は source が target に含まれることを表します
+	Subset
+	// Superset は source が target を含むことを表します
+	Superset
+	// Equal は source と target が等しいことを表します
+	Equal
+	// Undefined は関係を決定できないことを表します
+	// (target にワイルドカードが含まれる場合や、属性ごとに包含関係が逆転する場合)
+	Undefined
+)
+
+func (r Relation) String() string {
+	switch r {
+	case Disjoint:
+		return "DISJOINT"
+	case Subset:
+		return "SUBSET"
+	case Superset:
+		return "SUPERSET"
+	case Equal:
+		return "EQUAL"
+	default:
+		return "UNDEFINED"
+	}
+}
+
+// CompareAttributes は属性ごとの関係を返します
+func CompareAttributes(source, target Name) [attributeCount]Relation {
+	var relations [attributeCount]Relation
+	for i := range relations {
+		relations[i] = compareValues(source.values[i], target.values[i])
+	}
+	return relations
+}
+
+// Compare は名前全体の関係を返します。
+// いずれかの属性が DISJOINT なら DISJOINT、全ての属性が EQUAL なら EQUAL、
+// 全ての属性が SUPERSET か EQUAL なら SUPERSET、SUBSET か EQUAL なら SUBSET となります。
+func Compare(source, target Name) Relation {
+	relations := CompareAttributes(source, target)
+
+	superset, subset, undefined := true, true, false
+	for _, r := range relations {
+		switch r {
+		case Disjoint:
+			return Disjoint
+		case Superset:
+			subset = false
+		case Subset:
+			superset = false
+		case Undefined:
+			undefined = true
+		}
+	}
+
+	switch {
+	case undefined:
+		return Undefined
+	case superset && subset:
+		return Equal
+	case superset:
+		return Superset
+	case subset:
+		return Subset
+	default:
+		return Undefined
+	}
+}
+
+// IsDisjoint は source と target に共通するCPE名が存在しないかどうかを返します
+func IsDisjoint(source, target Name) bool {
+	return Compare(source, target) == Disjoint
+}
+
+// IsEqual は source と target が等しいかどうかを返します
+func IsEqual(source, target Name) bool {
+	return Compare(source, target) == Equal
+}
+
+// IsSuperset は source が target を含む (または等しい) かどうかを返します
+func IsSuperset(source, target Name) bool {
+	r := Compare(source, target)
+	return r == Superset || r == Equal
+}
+
+// IsSubset は source が target に含まれる (または等しい) かどうかを返します
+func IsSubset(source, target Name) bool {
+	r := Compare(source, target)
+	return r == Subset || r == Equal
+}
+
+// compareValues は属性値の関係を返します (NISTIR 7696 6.1.2)
+func compareValues(source, target Value) Relation {
+	if target.HasWildcards() {
+		return Undefined
+	}
+
+	if source.Kind == Literal && target.Kind == Literal {
+		s, t := strings.ToLower(source.wfn), strings.ToLower(target.wfn)
+		if s == t {
+			return Equal
+		}
+		return compareStrings(s, t)
+	}
+
+	switch {
+	case source.Kind == target.Kind:
+		return Equal
+	case source.Kind == Any:
+		return Superset
+	case target.Kind == Any:
+		return Subset
+	default:
+		// 一方が NA で他方が文字列
+		return Disjoint
+	}
+}
+
+// compareStrings はワイルドカードを含みうる source が target を含むかどうかを判定します
+func compareStrings(source, target string) Relation {
+	start, end := 0, len(source)
+	begins, ends := 0, 0
+
+	if strings.HasPrefix(source, "*") {
+		start = 1
+		begins = -1
+	} else {
+		for start < len(source) && source[start] == '?' {
+			start++
+			begins++
+		}
+	}
+
+	if end > start && source[end-1] == '*' && isEvenWildcard(source, end-1) {
+		end--
+		ends = -1
+	} else {
+		for end > start && source[end-1] == '?' && isEvenWildcard(source, end-1) {
+			end--
+			ends++
+		}
+	}
+
+	body := source[start:end]
+	index := -1
+	leftover := len(target)
+
+	for leftover > 0 {
+		next := strings.Index(target[index+1:], body)
+		if next == -1 {
+			break
+		}
+		index += next + 1
+
+		escapes := countEscapes(target, 0, index)
+		if index > 0 && begins != -1 && begins < index-escapes {
+			break
+		}
+
+		// body より後ろに残っている (クォートを除いた) 文字数
+		rest := index + len(body)
+		leftover = len(target) - rest - countEscapes(target, rest, len(target))
+		if leftover > 0 && ends != -1 && leftover > ends {
+			continue
+		}
+
+		return Superset
+	}
+
+	return Disjoint
+}
+
+// isEvenWildcard は idx の文字の直前にあるバックスラッシュが偶数個
+// (つまり idx の文字がクォートされていない) かどうかを返します
+func isEvenWildcard(s string, idx int) bool {
+	count := 0
+	for i := idx - 1; i >= 0 && s[i] == '\\'; i-- {
+		count++
+	}
+	return count%2 == 0
+}
+
+// countEscapes は s[start:end] に含まれるエスケープの数を返します
+func countEscapes(s string, start, end int) int {
+	count := 0
+	for i := start; i < end; i++ {
+		if s[i] == '\\' {
+			count++
+			i++
+		}
+	}
+	return count
+}
@@ -0,0 +1,169 @@
+package cpe
+
+import "testing"
+
+// withVersion は version 以外が同じ名前を作ります (属性単位の関係を名前全体で確認するため)
+func withVersion(t *testing.T, version string) Name {
+	t.Helper()
+
+	name, err := ParseFormattedString("cpe:2.3:a:vendor:product:" + version + ":*:*:*:*:*:*:*")
+	if err != nil {
+		t.Fatalf("ParseFormattedString(%q) error = %v", version, err)
+	}
+	return name
+}
+
+// NISTIR 7696 Table 6-2 (属性値の比較) の各行
+func TestCompareAttributeValues(t *testing.T) {
+	tests := []struct {
+		source, target string
+		want           Relation
+	}{
+		{"*", "*", Equal},
+		{"*", "-", Superset},
+		{"*", "1.0", Superset},
+		{"*", "1.*", Undefined},
+		{"-", "*", Subset},
+		{"-", "-", Equal},
+		{"-", "1.0", Disjoint},
+		{"-", "1.*", Undefined},
+		{"1.0", "*", Subset},
+		{"1.0", "-", Disjoint},
+		{"1.0", "1.0", Equal},
+		{"1.0", "1.1", Disjoint},
+		{"1.0", "1.*", Undefined},
+		{"1.*", "*", Subset},
+		{"1.*", "-", Disjoint},
+		{"1.*", "1.0", Superset},
+		{"1.*", "2.0", Disjoint},
+		{"1.*", "1.?", Undefined},
+	}
+
+	for _, tt := range tests {
+		if got := Compare(withVersion(t, tt.source), withVersion(t, tt.target)); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %s, want %s", tt.source, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestCompareWildcards(t *testing.T) {
+	tests := []struct {
+		source, target string
+		want           Relation
+	}{
+		{"1.*", "1.2.3", Superset},
+		{"*.3", "1.2.3", Superset},
+		{"*2*", "1.2.3", Superset},
+		{"1.*", "10.0", Disjoint},
+		// 端の '?' は1文字か、文字列の端では0文字に一致する
+		{"1.?", "1.2", Superset},
+		{"1.?", "1.", Superset},
+		{"1.?", "1.23", Disjoint},
+		{"1.??", "1.23", Superset},
+		{"?.2", "1.2", Superset},
+		{"?.2", "11.2", Disjoint},
+		{"??.2", "11.2", Superset},
+		// クォートされた '*' と '?' はワイルドカードではない
+		{`1\*`, "1.0", Disjoint},
+		{`1\?`, `1\?`, Equal},
+		// 文字列の比較は大文字と小文字を区別しない
+		{"RC1", "rc1", Equal},
+		{"RC*", "rc1", Superset},
+	}
+
+	for _, tt := range tests {
+		if got := Compare(withVersion(t, tt.source), withVersion(t, tt.target)); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %s, want %s", tt.source, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestCompareNames(t *testing.T) {
+	tests := []struct {
+		source, target string
+		want           Relation
+	}{
+		{
+			// NISTIR 7696 7.1 の例: target がワイルドカードを含む場合は UNDEFINED
+			`cpe:2.3:a:microsoft:internet_explorer:8.0.6001:beta:*:*:*:*:*:*`,
+			`cpe:2.3:a:microsoft:internet_explorer:8.*:sp?:*:*:*:*:*:*`,
+			Undefined,
+		},
+		{
+			// 同じ例の source と target を入れ替えたもの: update が一致しないため DISJOINT
+			`cpe:2.3:a:microsoft:internet_explorer:8.*:sp?:*:*:*:*:*:*`,
+			`cpe:2.3:a:microsoft:internet_explorer:8.0.6001:beta:*:*:*:*:*:*`,
+			Disjoint,
+		},
+		{
+			`cpe:2.3:a:microsoft:internet_explorer:8.*:sp?:*:*:*:*:*:*`,
+			`cpe:2.3:a:microsoft:internet_explorer:8.0.6001:sp2:*:*:*:*:*:*`,
+			Superset,
+		},
+		{
+			`cpe:2.3:a:adobe:*:*:*:*:*:*:*:*:*`,
+			`cpe:2.3:a:adobe:acrobat:9.0:-:*:*:*:*:*:*`,
+			Superset,
+		},
+		{
+			`cpe:2.3:a:adobe:acrobat:9.0:-:*:*:*:*:*:*`,
+			`cpe:2.3:a:adobe:*:*:*:*:*:*:*:*:*`,
+			Subset,
+		},
+		{
+			// 属性ごとに SUPERSET と SUBSET が混在する場合は UNDEFINED
+			`cpe:2.3:a:adobe:acrobat:*:-:*:*:*:*:*:*`,
+			`cpe:2.3:a:adobe:*:9.0:-:*:*:*:*:*:*`,
+			Undefined,
+		},
+		{
+			`cpe:/o:linux:linux_kernel:6.1`,
+			`cpe:2.3:o:linux:linux_kernel:6.1:*:*:*:*:*:*:*`,
+			Equal,
+		},
+		{
+			// URIのパーセントエンコードと形式化文字列のクォートは同じ値になる
+			`cpe:/a:foo%5cbar:big%24money`,
+			`cpe:2.3:a:foo\\bar:big\$money:*:*:*:*:*:*:*:*`,
+			Equal,
+		},
+		{
+			`cpe:2.3:o:linux:linux_kernel:*:*:*:*:*:*:*:*`,
+			`cpe:2.3:h:linux:linux_kernel:*:*:*:*:*:*:*:*`,
+			Disjoint,
+		},
+	}
+
+	for _, tt := range tests {
+		source, err := Parse(tt.source)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.source, err)
+		}
+		target, err := Parse(tt.target)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.target, err)
+		}
+
+		if got := Compare(source, target); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %s, want %s", tt.source, tt.target, got, tt.want)
+		}
+
+		want := map[string]bool{
+			"IsDisjoint": tt.want == Disjoint,
+			"IsEqual":    tt.want == Equal,
+			"IsSuperset": tt.want == Superset || tt.want == Equal,
+			"IsSubset":   tt.want == Subset || tt.want == Equal,
+		}
+		got := map[string]bool{
+			"IsDisjoint": IsDisjoint(source, target),
+			"IsEqual":    IsEqual(source, target),
+			"IsSuperset": IsSuperset(source, target),
+			"IsSubset":   IsSubset(source, target),
+		}
+		for fn := range want {
+			if got[fn] != want[fn] {
+				t.Errorf("%s(%s, %s) = %v, want %v", fn, tt.source, tt.target, got[fn], want[fn])
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -46,88 +47,132 @@ func CreateDatabaseIndex(ctx context.Context, db *mongo.Database) error {
 	}
 
 	log.Printf("Index '%s' (vulnerabilities.cve) ensured.", indexName)
-	
-	log.Print("Index check/creation complete.")
-	return nil
-}
 
-func CreateVulnerability(ctx context.Context, db *mongo.Database, v *Vulnerability) error {
-	log.Print("Starting database session...")
-	if db == nil || db.Client() == nil {
-		return fmt.Errorf("could not establish database session: client is nil")
+	productIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "productIds", Value: 1}},
 	}
 
-	session, err := db.Client().StartSession()
+	indexName, err = vulnCollection.Indexes().CreateOne(ctx, productIndexModel)
 	if err != nil {
-		return fmt.Errorf("failed to start database session: %w", err)
-	} else {
-		log.Print("Database session established.")
+		return fmt.Errorf("failed to create 'productIds' index for vulnerabilities: %w", err)
 	}
-	defer session.EndSession(ctx)
 
-	vulnCollection := db.Collection("vulnerabilities")
-	prodCollection := db.Collection("products")
+	log.Printf("Index '%s' (vulnerabilities.productIds) ensured.", indexName)
 
-	_, err = session.WithTransaction(ctx, func(sessCtx context.Context) (interface{}, error) {
-		// FindOne を使用して、同じCVEが存在するかどうかを確認
-		err := vulnCollection.FindOne(sessCtx, bson.M{"cve": v.CVE}).Err()
-		if err == nil {
-			log.Printf("Skipping CVE %s because it already exists.", v.CVE)
-			return nil, nil // エラーなしでトランザクションを終了（何もしない）
-		}
-		if err != mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("existing CVE check failed: %s", err)
-		}
+	tentativeIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "tentativeProductIds", Value: 1}},
+	}
 
-		now := time.Now()
-		v.CreatedAt = now
-		v.UpdatedAt = now
-		v.ID = bson.NewObjectID()
+	indexName, err = vulnCollection.Indexes().CreateOne(ctx, tentativeIndexModel)
+	if err != nil {
+		return fmt.Errorf("failed to create 'tentativeProductIds' index for vulnerabilities: %w", err)
+	}
 
-		if _, err := vulnCollection.InsertOne(sessCtx, v); err != nil {
-			return nil, fmt.Errorf("failed to insert document(s) to vulnerabilities collection: %w", err)
-		}
+	log.Printf("Index '%s' (vulnerabilities.tentativeProductIds) ensured.", indexName)
 
-		embeddedVuln := EmbeddedVulnerability{
-			CVE:         v.CVE,
-			GHSA:        v.GHSA,
-			PublishedAt: v.PublishedAt,
-			CVSS40:      v.CVSS40,
-			CVSS31:      v.CVSS31,
-			CVSS30:      v.CVSS30,
-			CVSS20:      v.CVSS20,
-		}
+	notAffectedIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "notAffectedProductIds", Value: 1}},
+	}
 
-		update := bson.M{
-			"$push": bson.M{
-				"recentVulnerabilities": bson.M{
-					"$each":  []EmbeddedVulnerability{embeddedVuln},
-					"$sort":  bson.M{"publishedAt": -1},
-					"$slice": MaxRecentVulnerabilities,
-				},
-			},
-		}
-		filter := bson.M{"_id": v.ProductID}
+	indexName, err = vulnCollection.Indexes().CreateOne(ctx, notAffectedIndexModel)
+	if err != nil {
+		return fmt.Errorf("failed to create 'notAffectedProductIds' index for vulnerabilities: %w", err)
+	}
 
-		res, err := prodCollection.UpdateOne(sessCtx, filter, update)
-		if err != nil {
-			return nil, fmt.Errorf("failed to update products collection: %w", err)
-		}
-		if res.MatchedCount == 0 {
-			return nil, fmt.Errorf("no products found matching productId %sん", v.ProductID)
-		}
+	log.Printf("Index '%s' (vulnerabilities.notAffectedProductIds) ensured.", indexName)
 
-		return nil, nil
-	})
+	revisionIndexModel := mongo.IndexModel{
+		Keys: bson.D{{Key: "cve", Value: 1}, {Key: "createdAt", Value: 1}},
+	}
 
+	indexName, err = db.Collection("vulnerability_revisions").Indexes().CreateOne(ctx, revisionIndexModel)
 	if err != nil {
-		return fmt.Errorf("vulnerability registration transaction failed: %w", err)
+		return fmt.Errorf("failed to create 'cve' index for vulnerability_revisions: %w", err)
 	}
 
+	log.Printf("Index '%s' (vulnerability_revisions.cve) ensured.", indexName)
+
+	log.Print("Index check/creation complete.")
 	return nil
 }
 
-func CreateVulnerabilityBatch(ctx context.Context, db *mongo.Database, vulns *[]Vulnerability) error {
+// MigrateDatabase は古い形式のドキュメントを現在の形式に変換します
+func MigrateDatabase(ctx context.Context, db *mongo.Database) error {
+	// 単一の productId を productIds の配列に変換する
+	filter := bson.M{
+		"productId":  bson.M{"$exists": true},
+		"productIds": bson.M{"$exists": false},
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"productIds": bson.A{"$productId"}}}},
+		{{Key: "$unset", Value: "productId"}},
+	}
+
+	res, err := db.Collection("vulnerabilities").UpdateMany(ctx, filter, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to migrate productId to productIds: %w", err)
+	}
+	if res.ModifiedCount > 0 {
+		log.Printf("Migrated %d vulnerabilities to productIds.", res.ModifiedCount)
+	}
+
+	return nil
+}
+
+// ListScoredCVEs は指定したCVEのうち、スコアのある脆弱性として登録済みのものを返します
+func ListScoredCVEs(ctx context.Context, db *mongo.Database, cves []string) (map[string]struct{}, error) {
+	filter := bson.M{
+		"cve": bson.M{"$in": cves},
+		"$or": bson.A{
+			bson.M{"cvss40": bson.M{"$exists": true}},
+			bson.M{"cvss31": bson.M{"$exists": true}},
+			bson.M{"cvss30": bson.M{"$exists": true}},
+			bson.M{"cvss20": bson.M{"$exists": true}},
+		},
+	}
+	opts := options.Find().SetProjection(bson.M{"cve": 1})
+
+	vulns, err := findVulnerabilities(ctx, db, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	scored := make(map[string]struct{}, len(vulns))
+	for _, v := range vulns {
+		scored[v.CVE] = struct{}{}
+	}
+	return scored, nil
+}
+
+// CreateVulnerability は脆弱性を1件登録します。既に存在する場合は変更された項目を更新します。
+func CreateVulnerability(ctx context.Context, db *mongo.Database, v *Vulnerability, runID string) error {
+	vulns := []Vulnerability{*v}
+	if err := UpsertVulnerabilityBatch(ctx, db, &vulns, runID); err != nil {
+		return err
+	}
+
+	*v = vulns[0]
+	return nil
+}
+
+// CreateVulnerabilityBatch は脆弱性をまとめて登録します。
+// 既に存在する脆弱性 (暫定的なマッチのみのものを含む) は、新しい製品へのマッチと抑制の追加のみを行い、その他の項目は更新しません
+// (NVD以外の提供元から、NVDの項目を上書きせずに製品を追加する場合に使います)。
+// 既存の脆弱性への変更は runID とともに vulnerability_revisions に記録します。
+func CreateVulnerabilityBatch(ctx context.Context, db *mongo.Database, vulns *[]Vulnerability, runID string) error {
+	return writeVulnerabilityBatch(ctx, db, vulns, runID, false)
+}
+
+// UpsertVulnerabilityBatch は脆弱性をまとめて登録し、既に存在する脆弱性は保存された内容と比較して
+// 変更された項目と UpdatedAt を更新します。製品とのマッチは今回の照合結果で置き換え (パッケージによるマッチは残します)、
+// マッチしなくなった製品を取り除きます。製品が1つもない脆弱性は、登録済みの場合にのみ更新します。
+// 製品の recentVulnerabilities に埋め込まれた内容も同じトランザクションで更新します。
+// 変更は runID とともに vulnerability_revisions に記録します。
+func UpsertVulnerabilityBatch(ctx context.Context, db *mongo.Database, vulns *[]Vulnerability, runID string) error {
+	return writeVulnerabilityBatch(ctx, db, vulns, runID, true)
+}
+
+func writeVulnerabilityBatch(ctx context.Context, db *mongo.Database, vulns *[]Vulnerability, runID string, upsert bool) error {
 	if len(*vulns) == 0 {
 		return nil
 	}
@@ -152,7 +197,6 @@ func CreateVulnerabilityBatch(ctx context.Context, db *mongo.Database, vulns *[]
 	_, err = session.WithTransaction(ctx, func(sessCtx context.Context) (interface{}, error) {
 		now := time.Now()
 
-
 		//処理対象の全CVE IDを収集
 		incomingCVEs := make([]string, 0, len(*vulns))
 		for _, v := range *vulns {
@@ -161,25 +205,22 @@ func CreateVulnerabilityBatch(ctx context.Context, db *mongo.Database, vulns *[]
 			}
 		}
 
-		existingCVEs := make(map[string]struct{})
+		existingCVEs := make(map[string]Vulnerability)
 		if len(incomingCVEs) > 0 {
 			filter := bson.M{"cve": bson.M{"$in": incomingCVEs}}
-			// 必要なのはCVEフィールドだけなので、Projectionで効率化
-			opts := options.Find().SetProjection(bson.M{"cve": 1, "_id": 0})
-			cursor, err := vulnCollection.Find(sessCtx, filter, opts)
+			// 変更の比較や暫定的なマッチの格上げのため、既存のドキュメント全体を取得
+			cursor, err := vulnCollection.Find(sessCtx, filter)
 			if err != nil {
 				return nil, fmt.Errorf("search for existing cve failed: %w", err)
 			}
 			defer cursor.Close(sessCtx)
 
 			for cursor.Next(sessCtx) {
-				var result struct {
-					CVE string `bson:"cve"`
-				}
+				var result Vulnerability
 				if err := cursor.Decode(&result); err != nil {
 					return nil, fmt.Errorf("failed to decode in cursor: %w", err)
 				}
-				existingCVEs[result.CVE] = struct{}{}
+				existingCVEs[result.CVE] = result
 			}
 			if err := cursor.Err(); err != nil {
 				return nil, fmt.Errorf("cursor error: %w", err)
@@ -187,46 +228,103 @@ func CreateVulnerabilityBatch(ctx context.Context, db *mongo.Database, vulns *[]
 		}
 
 		vulnDocs := make([]interface{}, 0)
+		var upgrades []mongo.WriteModel
 		prodVulnsMap := make(map[bson.ObjectID][]EmbeddedVulnerability)
-		newVulnsFoundCount := 0
+		// 埋め込まれた内容を書き換える製品の更新 (新しい脆弱性の追加より先に実行する)
+		var embeddedUpdates []mongo.WriteModel
+		var revisions []VulnerabilityRevision
+		// マッチしなくなった脆弱性を recentVulnerabilities から取り除くため、作り直す製品
+		var rebuilds []bson.ObjectID
 
 		for i := range *vulns {
 			v := &(*vulns)[i]
 
-			if _, exists := existingCVEs[v.CVE]; exists {
-				log.Printf("Skipping CVE %s because it already exists.", v.CVE)
-				continue // 存在する場合はスキップ
+			if existing, exists := existingCVEs[v.CVE]; exists {
+				// 暫定的なマッチのみで登録されていたCVEに設定が追加され、確定したマッチになった場合は格上げする
+				// (NVD以外の提供元からの追加では、NVDの項目を残すため製品とマッチの理由だけを追加する)
+				if !existing.IsTentative() || len(v.ProductIDs) == 0 || !upsert {
+					update, err := updateExisting(&existing, v, now, upsert)
+					if err != nil {
+						return nil, err
+					}
+					if update == nil {
+						log.Printf("Skipping CVE %s because it has not changed.", v.CVE)
+						continue // 変更がない場合はスキップ
+					}
+
+					log.Printf("Updating existing CVE %s (%s).", v.CVE, update.summary())
+					upgrades = append(upgrades, update.model)
+					revisions = append(revisions, newRevision(v, update.changes, runID, now))
+
+					if update.embeddedChanged {
+						embeddedUpdates = append(embeddedUpdates, rewriteEmbedded(update.embedded))
+					}
+
+					// 別の提供元 (NVDのCPEとOSVのパッケージなど) で新しい製品にマッチした場合は、その製品に追加する
+					for _, productID := range update.added {
+						prodVulnsMap[productID] = append(prodVulnsMap[productID], update.embedded)
+					}
+					for _, productID := range update.removed {
+						if !slices.Contains(rebuilds, productID) {
+							rebuilds = append(rebuilds, productID)
+						}
+					}
+					continue
+				}
+
+				log.Printf("Upgrading tentative CVE %s to a confirmed match.", v.CVE)
+				v.ID = existing.ID
+				v.CreatedAt = existing.CreatedAt
+				v.UpdatedAt = now
+
+				changes, err := diffVulnerabilities(&existing, v)
+				if err != nil {
+					return nil, err
+				}
+				revisions = append(revisions, newRevision(v, changes, runID, now))
+
+				upgrades = append(upgrades, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": existing.ID}).SetReplacement(v))
+			} else if len(v.ProductIDs) == 0 && len(v.TentativeProductIDs) == 0 && len(v.NotAffectedProductIDs) == 0 {
+				// マッチしなくなった製品を取り除くための脆弱性は、登録済みでなければ何もしない
+				// (抑制ルールで該当しないと判断されたマッチは、監査のために登録する)
+				continue
+			} else {
+				v.CreatedAt = now
+				v.UpdatedAt = now
+				v.ID = bson.NewObjectID()
+
+				vulnDocs = append(vulnDocs, v) // InsertManyの対象に追加
 			}
 
-			newVulnsFoundCount++
-			v.CreatedAt = now
-			v.UpdatedAt = now
-			v.ID = bson.NewObjectID()
-
-			vulnDocs = append(vulnDocs, v) // InsertManyの対象に追加
-
-			embeddedVuln := EmbeddedVulnerability{
-				CVE:         v.CVE,
-				GHSA:        v.GHSA,
-				PublishedAt: v.PublishedAt,
-				CVSS40:      v.CVSS40,
-				CVSS31:      v.CVSS31,
-				CVSS30:      v.CVSS30,
-				CVSS20:      v.CVSS20,
+			// 影響を受ける製品ごとにEmbeddedVulnerabilityをまとめる
+			// (暫定的なマッチは確定するまで製品には追加しない)
+			embeddedVuln := v.Embedded()
+			for _, productID := range v.ProductIDs {
+				prodVulnsMap[productID] = append(prodVulnsMap[productID], embeddedVuln)
 			}
-			// ProductIDごとにEmbeddedVulnerabilityをまとめる
-			prodVulnsMap[v.ProductID] = append(prodVulnsMap[v.ProductID], embeddedVuln)
 		}
 
-		if newVulnsFoundCount == 0 {
+		if len(vulnDocs) == 0 && len(upgrades) == 0 {
 			return nil, nil
 		}
 
-		if _, err := vulnCollection.InsertMany(sessCtx, vulnDocs); err != nil {
-			return nil, fmt.Errorf("insert many: failed: %w", err)
+		if len(vulnDocs) > 0 {
+			if _, err := vulnCollection.InsertMany(sessCtx, vulnDocs); err != nil {
+				return nil, fmt.Errorf("insert many: failed: %w", err)
+			}
 		}
 
-		var productUpdates []mongo.WriteModel
+		if len(upgrades) > 0 {
+			if _, err := vulnCollection.BulkWrite(sessCtx, upgrades); err != nil {
+				return nil, fmt.Errorf("failed to update existing vulnerabilities: %w", err)
+			}
+		}
+
+		if err := insertRevisions(sessCtx, db, revisions); err != nil {
+			return nil, err
+		}
+
+		productUpdates := embeddedUpdates
 		for prodID, newVulns := range prodVulnsMap {
 			filter := bson.M{"_id": prodID}
 			update := bson.M{
@@ -248,6 +346,12 @@ func CreateVulnerabilityBatch(ctx context.Context, db *mongo.Database, vulns *[]
 			}
 		}
 
+		for _, productID := range rebuilds {
+			if err := rebuildRecentVulnerabilities(sessCtx, db, productID); err != nil {
+				return nil, err
+			}
+		}
+
 		return nil, nil
 	})
 
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// testDatabase は TEST_DB_CONNECT_STRING のMongoDB (トランザクションのためレプリカセット) に
+// テスト用のデータベースを作ります。設定されていない場合はテストを読み飛ばします。
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("TEST_DB_CONNECT_STRING")
+	if uri == "" {
+		t.Skip("TEST_DB_CONNECT_STRING is not set")
+	}
+
+	ctx := context.Background()
+	database, err := NewDBClient(ctx, uri, "eleos-test-"+bson.NewObjectID().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.Drop(ctx)
+		database.Client().Disconnect(ctx)
+	})
+
+	if err := CreateDatabaseIndex(ctx, database); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func TestUpsertVulnerabilityBatchStoresSuppressedMatches(t *testing.T) {
+	database := testDatabase(t)
+	ctx := context.Background()
+
+	productID := bson.NewObjectID()
+	score := int32(75)
+	vulns := []Vulnerability{
+		{
+			// 唯一のマッチが抑制された新しいCVE
+			CVE:                   "CVE-2024-0001",
+			PublishedAt:           time.Now(),
+			CVSS31:                &score,
+			NotAffectedProductIDs: []bson.ObjectID{productID},
+			NotAffected: []NotAffected{{
+				ProductID:     productID,
+				Rule:          "cwe:CWE-79",
+				Justification: "no web interface",
+				SuppressedAt:  time.Now(),
+			}},
+		},
+		{
+			// どの製品にもマッチしなくなった未登録のCVE
+			CVE:         "CVE-2024-0002",
+			PublishedAt: time.Now(),
+			CVSS31:      &score,
+		},
+	}
+
+	if err := UpsertVulnerabilityBatch(ctx, database, &vulns, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	notAffected, err := ListNotAffectedVulnerabilities(ctx, database, productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notAffected) != 1 || notAffected[0].CVE != "CVE-2024-0001" {
+		t.Fatalf("ListNotAffectedVulnerabilities() = %v, want [CVE-2024-0001]", notAffected)
+	}
+
+	count, err := database.Collection("vulnerabilities").CountDocuments(ctx, bson.M{"cve": "CVE-2024-0002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("vulnerability without any products was inserted")
+	}
+}
+
+func TestUpdateExistingKeepsNVDFieldsWithoutUpsert(t *testing.T) {
+	tentativeProduct, packageProduct := bson.NewObjectID(), bson.NewObjectID()
+	score := int32(98)
+
+	// 暫定的なマッチのみで登録された、スコアのある脆弱性
+	existing := &Vulnerability{
+		ID:                  bson.NewObjectID(),
+		CVE:                 "CVE-2024-0003",
+		Description:         "description from NVD",
+		CWEs:                []string{"CWE-787"},
+		CVSS31:              &score,
+		CVSSScores:          []CVSSScore{{Version: "3.1", Source: "nvd@nist.gov", Score: score, Selected: true}},
+		TentativeProductIDs: []bson.ObjectID{tentativeProduct},
+		TentativeMatches:    []TentativeMatch{{ProductID: tentativeProduct, Rule: "keyword:example"}},
+	}
+	// OSVのパッケージによるマッチ (NVDの項目を持たない)
+	incoming := &Vulnerability{
+		CVE:            "CVE-2024-0003",
+		Description:    "description from OSV",
+		ProductIDs:     []bson.ObjectID{packageProduct},
+		PackageMatches: []PackageMatch{{ProductID: packageProduct, Rule: "pkg:npm/example", Source: "GHSA-xxxx-xxxx-xxxx"}},
+	}
+
+	update, err := updateExisting(existing, incoming, time.Now(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update == nil {
+		t.Fatal("updateExisting() = nil, want an update")
+	}
+
+	changed := make([]string, 0, len(update.changes))
+	for _, change := range update.changes {
+		changed = append(changed, change.Field)
+	}
+	want := []string{"productIds", "packageMatches"}
+	if !slices.Equal(changed, want) {
+		t.Errorf("changed fields = %v, want %v", changed, want)
+	}
+	if !slices.Equal(update.added, []bson.ObjectID{packageProduct}) {
+		t.Errorf("added = %v, want [%v]", update.added, packageProduct)
+	}
+	if update.embedded.CVSS31 == nil || *update.embedded.CVSS31 != score {
+		t.Errorf("embedded CVSS31 = %v, want %d", update.embedded.CVSS31, score)
+	}
+}
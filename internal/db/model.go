@@ -2,6 +2,7 @@
 package db
 
 import (
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -17,10 +18,127 @@ type EmbeddedVulnerability struct {
 	CVSS20 *int32 `bson:"cvss20,omitempty" json:"cvss20,omitempty"`
 }
 
+// CVSSScore は提供元 (NVD / CNA) ごとのCVSSスコアです
+type CVSSScore struct {
+	Version      string `bson:"version" json:"version"`
+	Source       string `bson:"source" json:"source"`
+	Type         string `bson:"type" json:"type"`
+	Score        int32  `bson:"score" json:"score"`
+	VectorString string `bson:"vectorString" json:"vectorString"`
+	BaseSeverity string `bson:"baseSeverity,omitempty" json:"baseSeverity,omitempty"`
+	// Selected はポリシーによってそのバージョンのスコア (cvss40 など) として採用されたかどうかです
+	Selected bool `bson:"selected,omitempty" json:"selected,omitempty"`
+}
+
+// Reference はCVEの参考情報のURLです
+type Reference struct {
+	URL    string `bson:"url" json:"url"`
+	Source string `bson:"source,omitempty" json:"source,omitempty"`
+	// Tags はNVDが付与した分類です (Patch, Exploit, Vendor Advisory など)
+	Tags []string `bson:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// ProductMatching は製品に脆弱性を紐づけるための照合条件です
+type ProductMatching struct {
+	Enabled bool `bson:"enabled" json:"enabled"`
+	// CPEs は製品に該当するCPE名のパターンです
+	CPEs []string `bson:"cpes" json:"cpes"`
+	// Exclude は CPEs に該当しても製品に含めないCPE名のパターンです
+	Exclude []string `bson:"exclude,omitempty" json:"exclude,omitempty"`
+	// VersionScheme はバージョンの比較方法です (dotted, windows-build, semver)
+	VersionScheme string `bson:"versionScheme,omitempty" json:"versionScheme,omitempty"`
+	// Versions は運用中のバージョンまたはその範囲です
+	Versions []string `bson:"versions,omitempty" json:"versions,omitempty"`
+	// Tentative は設定 (CPE) がまだないCVEを暫定的に紐づけるための条件です
+	Tentative *TentativeMatching `bson:"tentative,omitempty" json:"tentative,omitempty"`
+	// Packages は製品に該当するパッケージです (CPEで表せないライブラリなど)
+	Packages []PackageMatching `bson:"packages,omitempty" json:"packages,omitempty"`
+	// Suppressions は製品に該当しないことが分かっている脆弱性を除外するルールです
+	Suppressions []Suppression `bson:"suppressions,omitempty" json:"suppressions,omitempty"`
+}
+
+// Suppression は脆弱性を製品に該当しない (not affected) ものとして扱うルールです。
+// 指定された条件を全て満たすマッチに適用されます。
+type Suppression struct {
+	CVE string `bson:"cve,omitempty" json:"cve,omitempty"`
+	CWE string `bson:"cwe,omitempty" json:"cwe,omitempty"`
+	// CPE はマッチしたCPEに対するパターンです (全てのCPEがパターンに含まれる場合に適用されます)
+	CPE string `bson:"cpe,omitempty" json:"cpe,omitempty"`
+	// ExpiresAt を過ぎたルールは適用されなくなります (省略時は無期限)
+	ExpiresAt *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	// Justification は該当しない理由です
+	Justification string `bson:"justification" json:"justification"`
+}
+
+// PackageMatching はパッケージURLによる照合条件です
+type PackageMatching struct {
+	// Purl はバージョンを含まないパッケージURLです (pkg:golang/golang.org/x/net など)
+	Purl string `bson:"purl" json:"purl"`
+	// VersionScheme はバージョンの比較方法です (省略時は semver)
+	VersionScheme string `bson:"versionScheme,omitempty" json:"versionScheme,omitempty"`
+	// Versions は使用中のバージョンまたはその範囲です
+	Versions []string `bson:"versions,omitempty" json:"versions,omitempty"`
+}
+
+// TentativeMatching は説明文とCNAの識別子による暫定的な照合条件です。
+// いずれかの条件に該当したCVEを暫定的なマッチとして記録します。
+type TentativeMatching struct {
+	// Sources はCVEの sourceIdentifier (CNAのメールアドレスやUUID) です
+	Sources []string `bson:"sources,omitempty" json:"sources,omitempty"`
+	// Keywords は説明文に含まれる語句です (大文字と小文字を区別しません)
+	Keywords []string `bson:"keywords,omitempty" json:"keywords,omitempty"`
+	// Patterns は説明文に対する正規表現です
+	Patterns []string `bson:"patterns,omitempty" json:"patterns,omitempty"`
+}
+
 type Product struct {
 	ID                    bson.ObjectID           `bson:"_id" json:"id"`
 	Name                  string                  `bson:"name" json:"name"`
 	RecentVulnerabilities []EmbeddedVulnerability `bson:"recentVulnerabilities" json:"recentVulnerabilities"`
+	Matching              *ProductMatching        `bson:"matching,omitempty" json:"matching,omitempty"`
+}
+
+// MatchExplanation は脆弱性が製品に紐づけられた根拠 (マッチした脆弱なCPE) です
+type MatchExplanation struct {
+	ProductID bson.ObjectID `bson:"productId" json:"productId"`
+	// Rule はマッチした製品のCPEパターンです
+	Rule string `bson:"rule" json:"rule"`
+	// Configuration と Node はCVEの設定とNodeの位置 (0始まり) です
+	Configuration         int    `bson:"configuration" json:"configuration"`
+	Node                  int    `bson:"node" json:"node"`
+	Criteria              string `bson:"criteria" json:"criteria"`
+	MatchCriteriaID       string `bson:"matchCriteriaId" json:"matchCriteriaId"`
+	VersionStartIncluding string `bson:"versionStartIncluding,omitempty" json:"versionStartIncluding,omitempty"`
+	VersionStartExcluding string `bson:"versionStartExcluding,omitempty" json:"versionStartExcluding,omitempty"`
+	VersionEndIncluding   string `bson:"versionEndIncluding,omitempty" json:"versionEndIncluding,omitempty"`
+	VersionEndExcluding   string `bson:"versionEndExcluding,omitempty" json:"versionEndExcluding,omitempty"`
+}
+
+// PackageMatch は脆弱性がパッケージURLによって製品に紐づけられた根拠です
+type PackageMatch struct {
+	ProductID bson.ObjectID `bson:"productId" json:"productId"`
+	// Rule はマッチした製品のパッケージURLです
+	Rule string `bson:"rule" json:"rule"`
+	// Source は照合に使ったデータの提供元のIDです (OSVのIDなど)
+	Source string `bson:"source" json:"source"`
+	// Ranges は影響を受けるバージョンの範囲です
+	Ranges []string `bson:"ranges,omitempty" json:"ranges,omitempty"`
+}
+
+// NotAffected は抑制ルールによって製品に該当しないと判断されたマッチです
+type NotAffected struct {
+	ProductID bson.ObjectID `bson:"productId" json:"productId"`
+	// Rule は適用されたルールの条件です (cve:..., cwe:..., cpe:...)
+	Rule          string    `bson:"rule" json:"rule"`
+	Justification string    `bson:"justification" json:"justification"`
+	SuppressedAt  time.Time `bson:"suppressedAt" json:"suppressedAt"`
+}
+
+// TentativeMatch は設定がまだないCVEが暫定的に製品に紐づけられた根拠です
+type TentativeMatch struct {
+	ProductID bson.ObjectID `bson:"productId" json:"productId"`
+	// Rule は該当した条件です (source:..., keyword:..., pattern:...)
+	Rule string `bson:"rule" json:"rule"`
 }
 
 type Vulnerability struct {
@@ -31,9 +149,70 @@ type Vulnerability struct {
 	UpdatedAt   time.Time     `bson:"updatedAt" json:"updatedAt"`
 	PublishedAt time.Time     `bson:"publishedAt" json:"publishedAt"`
 	Description string        `bson:"description" json:"description"`
-	CVSS40      *int32        `bson:"cvss40,omitempty" json:"cvss40,omitempty"`
-	CVSS31      *int32        `bson:"cvss31,omitempty" json:"cvss31,omitempty"`
-	CVSS30      *int32        `bson:"cvss30,omitempty" json:"cvss30,omitempty"`
-	CVSS20      *int32        `bson:"cvss20,omitempty" json:"cvss20,omitempty"`
-	ProductID   bson.ObjectID `bson:"productId" json:"productId"`
+	// LastModified はNVDで最後に更新された日時です
+	LastModified time.Time `bson:"lastModified,omitempty" json:"lastModified,omitempty"`
+	// VulnStatus はNVDの解析状況です (Received, Analyzed, Modified など)
+	VulnStatus string      `bson:"vulnStatus,omitempty" json:"vulnStatus,omitempty"`
+	CWEs       []string    `bson:"cwes,omitempty" json:"cwes,omitempty"`
+	CVSS40     *int32      `bson:"cvss40,omitempty" json:"cvss40,omitempty"`
+	CVSS31     *int32      `bson:"cvss31,omitempty" json:"cvss31,omitempty"`
+	CVSS30     *int32      `bson:"cvss30,omitempty" json:"cvss30,omitempty"`
+	CVSS20     *int32      `bson:"cvss20,omitempty" json:"cvss20,omitempty"`
+	CVSSScores []CVSSScore `bson:"cvssScores,omitempty" json:"cvssScores,omitempty"`
+	// Severity は採用されたスコアのうち、最も新しいバージョンの深刻度です (CRITICAL, HIGH など)
+	Severity   string      `bson:"severity,omitempty" json:"severity,omitempty"`
+	References []Reference `bson:"references,omitempty" json:"references,omitempty"`
+	// ProductIDs はこの脆弱性の影響を受ける全ての製品です
+	ProductIDs []bson.ObjectID `bson:"productIds" json:"productIds"`
+	// Matches は各製品にマッチした理由です
+	Matches []MatchExplanation `bson:"matches,omitempty" json:"matches,omitempty"`
+	// PackageMatches はパッケージURLによって各製品にマッチした理由です
+	PackageMatches []PackageMatch `bson:"packageMatches,omitempty" json:"packageMatches,omitempty"`
+	// TentativeProductIDs は設定 (CPE) がまだなく、説明文などから暫定的に紐づけた製品です。
+	// 確定したマッチとは区別し、製品の recentVulnerabilities には追加しません。
+	TentativeProductIDs []bson.ObjectID  `bson:"tentativeProductIds,omitempty" json:"tentativeProductIds,omitempty"`
+	TentativeMatches    []TentativeMatch `bson:"tentativeMatches,omitempty" json:"tentativeMatches,omitempty"`
+	// NotAffectedProductIDs は抑制ルールによって該当しないと判断された製品です。
+	// ProductIDs には含めず、製品の recentVulnerabilities にも追加しません。
+	NotAffectedProductIDs []bson.ObjectID `bson:"notAffectedProductIds,omitempty" json:"notAffectedProductIds,omitempty"`
+	NotAffected           []NotAffected   `bson:"notAffected,omitempty" json:"notAffected,omitempty"`
+}
+
+// Criteria は製品にマッチしたCPEのcriteriaを重複なしで返します
+func (v *Vulnerability) Criteria(productID bson.ObjectID) []string {
+	var criteria []string
+	for _, m := range v.Matches {
+		if m.ProductID == productID && !slices.Contains(criteria, m.Criteria) {
+			criteria = append(criteria, m.Criteria)
+		}
+	}
+	return criteria
+}
+
+// hasPackageMatch はパッケージURLによって製品にマッチしているかどうかを返します
+func (v *Vulnerability) hasPackageMatch(productID bson.ObjectID) bool {
+	return slices.ContainsFunc(v.PackageMatches, func(m PackageMatch) bool { return m.ProductID == productID })
+}
+
+// IsTentative は暫定的なマッチのみで登録された脆弱性かどうかを返します
+func (v *Vulnerability) IsTentative() bool {
+	return len(v.ProductIDs) == 0 && len(v.TentativeProductIDs) > 0
+}
+
+// IsScored はいずれかのバージョンのCVSSスコアがあるかどうかを返します
+func (v *Vulnerability) IsScored() bool {
+	return v.CVSS40 != nil || v.CVSS31 != nil || v.CVSS30 != nil || v.CVSS20 != nil
+}
+
+// Embedded は製品ドキュメントの recentVulnerabilities に埋め込む形式に変換します
+func (v *Vulnerability) Embedded() EmbeddedVulnerability {
+	return EmbeddedVulnerability{
+		CVE:         v.CVE,
+		GHSA:        v.GHSA,
+		PublishedAt: v.PublishedAt,
+		CVSS40:      v.CVSS40,
+		CVSS31:      v.CVSS31,
+		CVSS30:      v.CVSS30,
+		CVSS20:      v.CVSS20,
+	}
 }
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ListProducts は全ての製品を取得します (recentVulnerabilities は含みません)
+func ListProducts(ctx context.Context, db *mongo.Database) ([]Product, error) {
+	opts := options.Find().SetProjection(bson.M{"recentVulnerabilities": 0})
+	cursor, err := db.Collection("products").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find products: %w", err)
+	}
+
+	var products []Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, fmt.Errorf("failed to decode products: %w", err)
+	}
+
+	return products, nil
+}
+
+// SeedProductMatching は照合条件がまだ設定されていない製品に初期値を設定します。
+// 製品が存在しない場合は作成し、既に照合条件がある場合は何もしません (管理画面での変更を優先します)。
+func SeedProductMatching(ctx context.Context, db *mongo.Database, id bson.ObjectID, name string, matching ProductMatching) error {
+	prodCollection := db.Collection("products")
+
+	var existing Product
+	err := prodCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		product := Product{
+			ID:                    id,
+			Name:                  name,
+			RecentVulnerabilities: []EmbeddedVulnerability{},
+			Matching:              &matching,
+		}
+		if _, err := prodCollection.InsertOne(ctx, product); err != nil {
+			return fmt.Errorf("failed to create product %s: %w", name, err)
+		}
+
+		log.Printf("Created product %s (%s).", name, id.Hex())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find product %s: %w", id.Hex(), err)
+	}
+
+	if existing.Matching != nil {
+		return nil
+	}
+
+	filter := bson.M{"_id": id, "matching": bson.M{"$exists": false}}
+	if _, err := prodCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"matching": matching}}); err != nil {
+		return fmt.Errorf("failed to set matching rules for product %s: %w", id.Hex(), err)
+	}
+
+	log.Printf("Seeded matching rules for product %s (%s).", existing.Name, id.Hex())
+	return nil
+}
+
+// SyncProductMatching は製品の照合条件を上書きします。製品が存在しない場合は作成します。
+func SyncProductMatching(ctx context.Context, db *mongo.Database, id bson.ObjectID, name string, matching ProductMatching) error {
+	update := bson.M{
+		"$set":         bson.M{"name": name, "matching": matching},
+		"$setOnInsert": bson.M{"recentVulnerabilities": []EmbeddedVulnerability{}},
+	}
+
+	if _, err := db.Collection("products").UpdateByID(ctx, id, update, options.UpdateOne().SetUpsert(true)); err != nil {
+		return fmt.Errorf("failed to sync matching rules for product %s: %w", name, err)
+	}
+
+	log.Printf("Synced matching rules for product %s (%s).", name, id.Hex())
+	return nil
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// FieldChange は脆弱性の1つの項目の変更です。
+// Old と New は変更前後のBSONの値で、項目が追加された場合は Old、削除された場合は New が nil になります。
+type FieldChange struct {
+	Field string `bson:"field" json:"field"`
+	Old   any    `bson:"old,omitempty" json:"old,omitempty"`
+	New   any    `bson:"new,omitempty" json:"new,omitempty"`
+}
+
+// VulnerabilityRevision は登録済みの脆弱性に対する1回の変更です
+type VulnerabilityRevision struct {
+	ID  bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	CVE string        `bson:"cve" json:"cve"`
+	// RunID は変更を書き込んだ取り込み処理の実行IDです
+	RunID string `bson:"runId" json:"runId"`
+	// LastModified は変更後の脆弱性のNVDの lastModified です
+	LastModified time.Time     `bson:"lastModified,omitempty" json:"lastModified,omitempty"`
+	Changes      []FieldChange `bson:"changes" json:"changes"`
+	CreatedAt    time.Time     `bson:"createdAt" json:"createdAt"`
+}
+
+// revisionIgnoredFields は変更履歴に記録しない項目です
+var revisionIgnoredFields = []string{"_id", "createdAt", "updatedAt"}
+
+// diffVulnerabilities は保存される形式 (BSON) で2つの脆弱性を比較し、項目ごとの変更を返します
+func diffVulnerabilities(before, after *Vulnerability) ([]FieldChange, error) {
+	beforeDoc, err := bson.Marshal(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vulnerability %s: %w", before.CVE, err)
+	}
+	afterDoc, err := bson.Marshal(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vulnerability %s: %w", after.CVE, err)
+	}
+
+	beforeElements, err := bson.Raw(beforeDoc).Elements()
+	if err != nil {
+		return nil, err
+	}
+	afterElements, err := bson.Raw(afterDoc).Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	for _, element := range beforeElements {
+		key := element.Key()
+		if slices.Contains(revisionIgnoredFields, key) {
+			continue
+		}
+
+		old := element.Value()
+		updated, err := bson.Raw(afterDoc).LookupErr(key)
+		switch {
+		case err != nil:
+			changes = append(changes, FieldChange{Field: key, Old: old})
+		case !old.Equal(updated):
+			changes = append(changes, FieldChange{Field: key, Old: old, New: updated})
+		}
+	}
+
+	for _, element := range afterElements {
+		key := element.Key()
+		if slices.Contains(revisionIgnoredFields, key) {
+			continue
+		}
+
+		if _, err := bson.Raw(beforeDoc).LookupErr(key); err != nil {
+			changes = append(changes, FieldChange{Field: key, New: element.Value()})
+		}
+	}
+
+	return changes, nil
+}
+
+// newRevision は変更後の脆弱性に対する変更履歴を作ります
+func newRevision(after *Vulnerability, changes []FieldChange, runID string, now time.Time) VulnerabilityRevision {
+	return VulnerabilityRevision{
+		ID:           bson.NewObjectID(),
+		CVE:          after.CVE,
+		RunID:        runID,
+		LastModified: after.LastModified,
+		Changes:      changes,
+		CreatedAt:    now,
+	}
+}
+
+// insertRevisions は変更履歴を vulnerability_revisions コレクションに書き込みます
+func insertRevisions(ctx context.Context, db *mongo.Database, revisions []VulnerabilityRevision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(revisions))
+	for _, revision := range revisions {
+		docs = append(docs, revision)
+	}
+
+	if _, err := db.Collection("vulnerability_revisions").InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("failed to insert vulnerability revisions: %w", err)
+	}
+
+	return nil
+}
+
+// ListVulnerabilityRevisions はCVEの変更履歴を古い順に取得します
+func ListVulnerabilityRevisions(ctx context.Context, db *mongo.Database, cve string) ([]VulnerabilityRevision, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := db.Collection("vulnerability_revisions").Find(ctx, bson.M{"cve": cve}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find revisions of %s: %w", cve, err)
+	}
+
+	var revisions []VulnerabilityRevision
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, fmt.Errorf("failed to decode revisions of %s: %w", cve, err)
+	}
+
+	return revisions, nil
+}
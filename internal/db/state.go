@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// IngestionState は取り込み処理ごとの進捗 (どこまで取り込んだか) を保持します
+type IngestionState struct {
+	ID            string    `bson:"_id" json:"id"`
+	HighWaterMark time.Time `bson:"highWaterMark" json:"highWaterMark"`
+	UpdatedAt     time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// GetIngestionState は指定したキーの進捗を取得します。まだ記録がない場合は nil を返します。
+func GetIngestionState(ctx context.Context, db *mongo.Database, key string) (*IngestionState, error) {
+	var state IngestionState
+
+	err := db.Collection("ingestion_state").FindOne(ctx, bson.M{"_id": key}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find ingestion state %s: %w", key, err)
+	}
+
+	return &state, nil
+}
+
+// SetIngestionHighWaterMark は指定したキーの進捗を記録します
+func SetIngestionHighWaterMark(ctx context.Context, db *mongo.Database, key string, mark time.Time) error {
+	update := bson.M{
+		"$set": bson.M{
+			"highWaterMark": mark,
+			"updatedAt":     time.Now(),
+		},
+	}
+
+	_, err := db.Collection("ingestion_state").UpdateOne(ctx, bson.M{"_id": key}, update, options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to update ingestion state %s: %w", key, err)
+	}
+
+	return nil
+}
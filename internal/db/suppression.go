@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ListAffectedVulnerabilities は製品に紐づけられた脆弱性を、抑制ルールの判定に必要な項目だけ取得します
+func ListAffectedVulnerabilities(ctx context.Context, db *mongo.Database, productID bson.ObjectID) ([]Vulnerability, error) {
+	opts := options.Find().SetProjection(bson.M{"cve": 1, "cwes": 1, "matches": 1})
+	return findVulnerabilities(ctx, db, bson.M{"productIds": productID}, opts)
+}
+
+// ListNotAffectedVulnerabilities は抑制ルールによって製品に該当しないと判断された脆弱性を公開日の新しい順に取得します
+func ListNotAffectedVulnerabilities(ctx context.Context, db *mongo.Database, productID bson.ObjectID) ([]Vulnerability, error) {
+	opts := options.Find().SetSort(bson.M{"publishedAt": -1})
+	return findVulnerabilities(ctx, db, bson.M{"notAffectedProductIds": productID}, opts)
+}
+
+func findVulnerabilities(ctx context.Context, db *mongo.Database, filter bson.M, opts *options.FindOptionsBuilder) ([]Vulnerability, error) {
+	cursor, err := db.Collection("vulnerabilities").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find vulnerabilities: %w", err)
+	}
+
+	var vulns []Vulnerability
+	if err := cursor.All(ctx, &vulns); err != nil {
+		return nil, fmt.Errorf("failed to decode vulnerabilities: %w", err)
+	}
+
+	return vulns, nil
+}
+
+// UpdateSuppressions は製品への既存のマッチに抑制ルールの変更を反映し、製品の recentVulnerabilities を作り直します。
+// suppress のCVEは該当しないものとして productIds から外し、restore のCVEは productIds に戻します。
+// 変更は runID とともに vulnerability_revisions に記録します。
+func UpdateSuppressions(ctx context.Context, db *mongo.Database, productID bson.ObjectID, suppress map[string]NotAffected, restore []string, runID string) error {
+	if len(suppress) == 0 && len(restore) == 0 {
+		return nil
+	}
+
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	vulnCollection := db.Collection("vulnerabilities")
+
+	_, err = session.WithTransaction(ctx, func(sessCtx context.Context) (interface{}, error) {
+		now := time.Now()
+		var models []mongo.WriteModel
+
+		for cve, notAffected := range suppress {
+			update := bson.M{
+				"$set":      bson.M{"updatedAt": now},
+				"$pull":     bson.M{"productIds": productID},
+				"$addToSet": bson.M{"notAffectedProductIds": productID},
+				"$push":     bson.M{"notAffected": notAffected},
+			}
+			filter := bson.M{"cve": cve, "productIds": productID}
+			models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update))
+		}
+
+		for _, cve := range restore {
+			update := bson.M{
+				"$set": bson.M{"updatedAt": now},
+				"$pull": bson.M{
+					"notAffectedProductIds": productID,
+					"notAffected":           bson.M{"productId": productID},
+				},
+				"$addToSet": bson.M{"productIds": productID},
+			}
+			filter := bson.M{"cve": cve, "notAffectedProductIds": productID}
+			models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update))
+		}
+
+		revisions, err := suppressionRevisions(sessCtx, db, productID, suppress, restore, runID, now)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := vulnCollection.BulkWrite(sessCtx, models); err != nil {
+			return nil, fmt.Errorf("failed to update suppressed vulnerabilities: %w", err)
+		}
+
+		if err := insertRevisions(sessCtx, db, revisions); err != nil {
+			return nil, err
+		}
+
+		return nil, rebuildRecentVulnerabilities(sessCtx, db, productID)
+	})
+
+	if err != nil {
+		return fmt.Errorf("suppression update transaction failed: %w", err)
+	}
+
+	log.Printf("Suppressed %d and restored %d vulnerabilities for product %s.", len(suppress), len(restore), productID.Hex())
+	return nil
+}
+
+// suppressionRevisions は抑制ルールの変更を反映する前の脆弱性を取得し、反映後との差分を変更履歴として返します
+func suppressionRevisions(ctx context.Context, db *mongo.Database, productID bson.ObjectID, suppress map[string]NotAffected, restore []string, runID string, now time.Time) ([]VulnerabilityRevision, error) {
+	cves := slices.AppendSeq(slices.Clone(restore), maps.Keys(suppress))
+	vulns, err := findVulnerabilities(ctx, db, bson.M{"cve": bson.M{"$in": cves}}, options.Find())
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []VulnerabilityRevision
+	for _, before := range vulns {
+		after := before
+
+		if notAffected, ok := suppress[before.CVE]; ok && slices.Contains(before.ProductIDs, productID) {
+			after.ProductIDs = slices.DeleteFunc(slices.Clone(before.ProductIDs), func(id bson.ObjectID) bool { return id == productID })
+			after.NotAffectedProductIDs = append(slices.Clone(before.NotAffectedProductIDs), productID)
+			after.NotAffected = append(slices.Clone(before.NotAffected), notAffected)
+		} else if slices.Contains(restore, before.CVE) && slices.Contains(before.NotAffectedProductIDs, productID) {
+			after.NotAffectedProductIDs = slices.DeleteFunc(slices.Clone(before.NotAffectedProductIDs), func(id bson.ObjectID) bool { return id == productID })
+			after.NotAffected = slices.DeleteFunc(slices.Clone(before.NotAffected), func(n NotAffected) bool { return n.ProductID == productID })
+			after.ProductIDs = append(slices.Clone(before.ProductIDs), productID)
+		} else {
+			continue
+		}
+
+		changes, err := diffVulnerabilities(&before, &after)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 {
+			revisions = append(revisions, newRevision(&after, changes, runID, now))
+		}
+	}
+
+	return revisions, nil
+}
+
+// rebuildRecentVulnerabilities は製品に紐づけられた脆弱性から recentVulnerabilities を作り直します
+func rebuildRecentVulnerabilities(ctx context.Context, db *mongo.Database, productID bson.ObjectID) error {
+	opts := options.Find().SetSort(bson.M{"publishedAt": -1}).SetLimit(MaxRecentVulnerabilities)
+	vulns, err := findVulnerabilities(ctx, db, bson.M{"productIds": productID}, opts)
+	if err != nil {
+		return err
+	}
+
+	recent := make([]EmbeddedVulnerability, 0, len(vulns))
+	for _, v := range vulns {
+		recent = append(recent, v.Embedded())
+	}
+
+	update := bson.M{"$set": bson.M{"recentVulnerabilities": recent}}
+	if _, err := db.Collection("products").UpdateByID(ctx, productID, update); err != nil {
+		return fmt.Errorf("failed to rebuild recent vulnerabilities: %w", err)
+	}
+
+	return nil
+}
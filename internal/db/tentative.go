@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// tentativeOnly は暫定的なマッチのみで登録された (確定したマッチがない) 脆弱性の条件を filter に加えます
+func tentativeOnly(filter bson.M) bson.M {
+	filter["tentativeProductIds.0"] = bson.M{"$exists": true}
+	filter["productIds.0"] = bson.M{"$exists": false}
+	return filter
+}
+
+// ListTentativeVulnerabilities は製品に暫定的に紐づけられた脆弱性を公開日の新しい順に取得します
+func ListTentativeVulnerabilities(ctx context.Context, db *mongo.Database, productID bson.ObjectID, limit int64) ([]Vulnerability, error) {
+	filter := tentativeOnly(bson.M{"tentativeProductIds": productID})
+
+	opts := options.Find().SetSort(bson.M{"publishedAt": -1}).SetLimit(limit)
+	cursor, err := db.Collection("vulnerabilities").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tentative vulnerabilities: %w", err)
+	}
+
+	var vulns []Vulnerability
+	if err := cursor.All(ctx, &vulns); err != nil {
+		return nil, fmt.Errorf("failed to decode tentative vulnerabilities: %w", err)
+	}
+
+	return vulns, nil
+}
+
+// DiscardTentativeVulnerabilities は設定が追加された結果どの製品にもマッチしなかったCVEについて、
+// 暫定的なマッチのみで登録されていた脆弱性を削除します
+func DiscardTentativeVulnerabilities(ctx context.Context, db *mongo.Database, cves []string) (int64, error) {
+	if len(cves) == 0 {
+		return 0, nil
+	}
+
+	filter := tentativeOnly(bson.M{"cve": bson.M{"$in": cves}})
+
+	res, err := db.Collection("vulnerabilities").DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to discard tentative vulnerabilities: %w", err)
+	}
+
+	return res.DeletedCount, nil
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// existingUpdate は既存の脆弱性に対する更新です
+type existingUpdate struct {
+	model mongo.WriteModel
+	// changes は項目ごとの変更内容です
+	changes []FieldChange
+	// added は新しくマッチした製品です
+	added []bson.ObjectID
+	// removed はマッチしなくなった (または抑制ルールで該当しなくなった) 製品です
+	removed []bson.ObjectID
+	// embedded は更新後の製品に埋め込む内容です
+	embedded EmbeddedVulnerability
+	// embeddedChanged は埋め込まれた内容 (スコアや公開日など) が変わったかどうかです
+	embeddedChanged bool
+}
+
+func (u *existingUpdate) summary() string {
+	fields := make([]string, 0, len(u.changes))
+	for _, change := range u.changes {
+		fields = append(fields, change.Field)
+	}
+
+	summary := "changed: " + strings.Join(fields, ", ")
+	if len(u.added) > 0 {
+		summary += fmt.Sprintf("; %d new products", len(u.added))
+	}
+	if len(u.removed) > 0 {
+		summary += fmt.Sprintf("; %d removed products", len(u.removed))
+	}
+	return summary
+}
+
+// updateExisting は既存の脆弱性を incoming の内容で更新するための変更を返します。
+// upsert が false の場合は新しい製品へのマッチと抑制の追加のみを行います。変更がない場合は nil を返します。
+func updateExisting(existing, incoming *Vulnerability, now time.Time, upsert bool) (*existingUpdate, error) {
+	update := &existingUpdate{}
+	after := *existing
+
+	if upsert {
+		// NVD以外の提供元が設定したGHSAは残す
+		if incoming.GHSA == nil {
+			incoming.GHSA = existing.GHSA
+		}
+		applyNVDFields(&after, incoming)
+		applyMatchedProducts(&after, existing, incoming)
+	} else {
+		addMatchedProducts(&after, existing, incoming)
+	}
+
+	for _, productID := range after.ProductIDs {
+		if !slices.Contains(existing.ProductIDs, productID) {
+			update.added = append(update.added, productID)
+		}
+	}
+	for _, productID := range existing.ProductIDs {
+		if !slices.Contains(after.ProductIDs, productID) {
+			update.removed = append(update.removed, productID)
+		}
+	}
+
+	// 別の提供元 (NVDのCPEとOSVのパッケージなど) で新しい製品にマッチした場合は、その製品のマッチの理由を追加する
+	if len(update.added) > 0 {
+		after.PackageMatches = append(slices.Clone(existing.PackageMatches),
+			matchesFor(incoming.PackageMatches, update.added, func(m PackageMatch) bson.ObjectID { return m.ProductID })...)
+	}
+
+	changes, err := diffVulnerabilities(existing, &after)
+	if err != nil {
+		return nil, err
+	}
+
+	// NVDの更新日時だけが変わった場合 (保存していない項目の変更) は更新しない
+	if len(changes) == 0 || len(changes) == 1 && changes[0].Field == "lastModified" {
+		return nil, nil
+	}
+
+	set := bson.M{"updatedAt": now}
+	unset := bson.M{}
+	for _, change := range changes {
+		if change.New == nil {
+			unset[change.Field] = ""
+		} else {
+			set[change.Field] = change.New
+		}
+		update.embeddedChanged = update.embeddedChanged || slices.Contains(embeddedFields, change.Field)
+	}
+
+	fields := bson.M{"$set": set}
+	if len(unset) > 0 {
+		fields["$unset"] = unset
+	}
+
+	update.changes = changes
+	update.embedded = after.Embedded()
+	update.model = mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": existing.ID}).SetUpdate(fields)
+	return update, nil
+}
+
+// applyNVDFields はNVDから取得される項目を incoming の内容で置き換えます
+func applyNVDFields(v, incoming *Vulnerability) {
+	v.Description = incoming.Description
+	v.PublishedAt = incoming.PublishedAt
+	v.LastModified = incoming.LastModified
+	v.GHSA = incoming.GHSA
+	v.CWEs = incoming.CWEs
+	v.CVSS40 = incoming.CVSS40
+	v.CVSS31 = incoming.CVSS31
+	v.CVSS30 = incoming.CVSS30
+	v.CVSS20 = incoming.CVSS20
+	v.CVSSScores = incoming.CVSSScores
+	v.Severity = incoming.Severity
+	v.VulnStatus = incoming.VulnStatus
+	v.References = incoming.References
+}
+
+// applyMatchedProducts は製品とのマッチを incoming の照合結果で置き換えます。
+// incoming で照合されなかったパッケージによるマッチは残し、それ以外のマッチしなくなった製品は取り除きます。
+func applyMatchedProducts(v, existing, incoming *Vulnerability) {
+	// パッケージでマッチした製品はOSVの取り込みで照合されるため、NVDの照合結果で抑制されない限り残す
+	kept := func(productID bson.ObjectID) bool {
+		return slices.Contains(incoming.ProductIDs, productID) ||
+			!slices.Contains(incoming.NotAffectedProductIDs, productID) && existing.hasPackageMatch(productID)
+	}
+
+	v.ProductIDs = slices.DeleteFunc(slices.Clone(existing.ProductIDs), func(id bson.ObjectID) bool { return !kept(id) })
+	for _, productID := range incoming.ProductIDs {
+		if !slices.Contains(v.ProductIDs, productID) {
+			v.ProductIDs = append(v.ProductIDs, productID)
+		}
+	}
+	v.Matches = incoming.Matches
+
+	v.NotAffectedProductIDs = nil
+	v.NotAffected = nil
+	for i, productID := range incoming.NotAffectedProductIDs {
+		notAffected := incoming.NotAffected[i]
+		// 同じルールで抑制され続けている場合は、最初に抑制された日時を残す
+		if j := slices.Index(existing.NotAffectedProductIDs, productID); j >= 0 && existing.NotAffected[j].Rule == notAffected.Rule {
+			notAffected = existing.NotAffected[j]
+		}
+		v.NotAffectedProductIDs = append(v.NotAffectedProductIDs, productID)
+		v.NotAffected = append(v.NotAffected, notAffected)
+	}
+	for i, productID := range existing.NotAffectedProductIDs {
+		if existing.hasPackageMatch(productID) && !slices.Contains(v.NotAffectedProductIDs, productID) && !slices.Contains(v.ProductIDs, productID) {
+			v.NotAffectedProductIDs = append(v.NotAffectedProductIDs, productID)
+			v.NotAffected = append(v.NotAffected, existing.NotAffected[i])
+		}
+	}
+
+	v.TentativeProductIDs = incoming.TentativeProductIDs
+	v.TentativeMatches = incoming.TentativeMatches
+}
+
+// addMatchedProducts は incoming で新しくマッチした製品と、新しく抑制された製品を追加します
+func addMatchedProducts(v, existing, incoming *Vulnerability) {
+	for _, productID := range incoming.ProductIDs {
+		if !slices.Contains(existing.ProductIDs, productID) {
+			v.ProductIDs = append(slices.Clip(v.ProductIDs), productID)
+			v.Matches = append(slices.Clip(v.Matches),
+				matchesFor(incoming.Matches, []bson.ObjectID{productID}, func(m MatchExplanation) bson.ObjectID { return m.ProductID })...)
+		}
+	}
+
+	for i, productID := range incoming.NotAffectedProductIDs {
+		if !slices.Contains(existing.ProductIDs, productID) && !slices.Contains(existing.NotAffectedProductIDs, productID) {
+			v.NotAffectedProductIDs = append(slices.Clip(v.NotAffectedProductIDs), productID)
+			v.NotAffected = append(slices.Clip(v.NotAffected), incoming.NotAffected[i])
+		}
+	}
+}
+
+// matchesFor はマッチの理由のうち、指定した製品のものを返します
+func matchesFor[T any](matches []T, productIDs []bson.ObjectID, productID func(T) bson.ObjectID) []T {
+	var filtered []T
+	for _, m := range matches {
+		if slices.Contains(productIDs, productID(m)) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// embeddedFields は EmbeddedVulnerability として製品に埋め込まれる項目のBSONのキーです
+var embeddedFields = []string{"ghsa", "publishedAt", "cvss40", "cvss31", "cvss30", "cvss20"}
+
+// rewriteEmbedded は recentVulnerabilities に埋め込まれた脆弱性を、全ての製品で新しい内容に書き換えます
+func rewriteEmbedded(embedded EmbeddedVulnerability) mongo.WriteModel {
+	filter := bson.M{"recentVulnerabilities.cve": embedded.CVE}
+	update := bson.M{"$set": bson.M{"recentVulnerabilities.$[v]": embedded}}
+	return mongo.NewUpdateManyModel().
+		SetFilter(filter).
+		SetUpdate(update).
+		SetArrayFilters([]interface{}{bson.M{"v.cve": embedded.CVE}})
+}
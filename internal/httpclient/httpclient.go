@@ -0,0 +1,161 @@
+// Package httpclient はNVDやOSVなどの外部APIのクライアントで共通に使う、
+// ステータスエラーとリトライ (ジッター付きの指数バックオフ) を提供します。
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultUserAgent は外部APIへのリクエストに使うデフォルトの User-Agent です
+const DefaultUserAgent = "eleos (+https://github.com/nexryai/eleos)"
+
+const (
+	// MaxRetries は1回のリクエストをリトライする最大回数です
+	MaxRetries     = 5
+	initialBackoff = 2 * time.Second
+	maxBackoff     = 2 * time.Minute
+)
+
+// StatusError はAPIが200以外のステータスを返したことを表します
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Message はAPIが返したエラー詳細です (ヘッダーやボディなど、APIごとに異なります)
+	Message    string
+	RetryAfter time.Duration
+}
+
+// NewStatusError はレスポンスのステータスと Retry-After ヘッダーから StatusError を作ります
+func NewStatusError(resp *http.Response, message string) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+// parseRetryAfter は秒数またはHTTP日付形式の Retry-After ヘッダーを解釈します
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+func retryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+// backoff はジッター付きの指数バックオフ時間を返します。
+// Retry-After が指定されている場合はそれより短くならないようにします。
+func backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := initialBackoff << (attempt - 1)
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+
+	// [d/2, d) の範囲でランダムに散らす
+	d = d/2 + rand.N(d/2)
+
+	if retryAfter > d {
+		return retryAfter
+	}
+	return d
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Retry は fn が成功するまで、retryable が true を返すエラーの場合にバックオフしながら最大 MaxRetries 回リトライします。
+// name はログに出力するリクエストの種類です。
+func Retry[T any](ctx context.Context, name string, retryable func(error) bool, fn func() (T, error)) (T, error) {
+	var zero T
+	var lastErr error
+
+	for attempt := 0; attempt <= MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := backoff(attempt, retryAfter(lastErr))
+			log.Printf("Retrying %s in %s (attempt %d/%d): %v", name, wait, attempt, MaxRetries, lastErr)
+
+			if err := sleep(ctx, wait); err != nil {
+				return zero, err
+			}
+		}
+
+		result, err := fn()
+		if err == nil {
+			return result, nil
+		}
+		if !retryable(err) {
+			return zero, err
+		}
+
+		lastErr = err
+	}
+
+	return zero, fmt.Errorf("giving up after %d attempts: %w", MaxRetries+1, lastErr)
+}
+
+// IsRetryable はリトライで回復しうるエラーかどうかを判定します。
+// StatusError のステータスがリトライ対象かどうかは、APIごとに temporary で判定します。
+func IsRetryable(ctx context.Context, err error, temporary func(statusCode int) bool) bool {
+	// 呼び出し元がキャンセルした場合はリトライしない
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return temporary(statusErr.StatusCode)
+	}
+
+	// リクエスト単位のタイムアウトやネットワークエラー
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &urlErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
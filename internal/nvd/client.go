@@ -0,0 +1,86 @@
+package nvd
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/nexryai/eleos/internal/httpclient"
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultBaseURL  = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+	DefaultPageSize = 100
+	// MaxPageSize はNVD APIが1ページで返せる脆弱性の上限です
+	MaxPageSize = 2000
+
+	apiKeyHeader      = "apiKey"
+	apiKeyEnvVariable = "NVD_API_KEY"
+)
+
+// Client はNVD CVE API 2.0 のクライアントです
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	apiKey     string
+	pageSize   int
+	limiter    *rate.Limiter
+}
+
+type Option func(*Client)
+
+// WithBaseURL はAPIのエンドポイントを変更します (ミラーやキャッシュプロキシなど)
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "?")
+	}
+}
+
+// WithHTTPClient はリクエストに使う http.Client を変更します
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithAPIKey はAPIキーを設定します。空文字列の場合は匿名でアクセスします。
+func WithAPIKey(apiKey string) Option {
+	return func(c *Client) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithPageSize は1ページあたりの取得件数を設定します (1 から MaxPageSize まで)
+func WithPageSize(pageSize int) Option {
+	return func(c *Client) {
+		c.pageSize = min(max(pageSize, 1), MaxPageSize)
+	}
+}
+
+// NewClient はクライアントを生成します。
+// APIキーはオプションで指定しない場合、環境変数 NVD_API_KEY から読み込みます。
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: &http.Client{},
+		userAgent:  httpclient.DefaultUserAgent,
+		apiKey:     os.Getenv(apiKeyEnvVariable),
+		pageSize:   DefaultPageSize,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.limiter = sharedLimiter(c.apiKey != "")
+
+	return c
+}
@@ -0,0 +1,30 @@
+package nvd
+
+import "time"
+
+// MaxDateRange はNVD APIで1回のリクエストに指定できる期間の上限です
+const MaxDateRange = 120 * 24 * time.Hour
+
+// DateRange はNVD APIに渡す期間を表します
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// SplitDateRange は期間を MaxDateRange 以下の連続した期間に分割します。
+// start が end 以降の場合は空のスライスを返します。
+func SplitDateRange(start, end time.Time) []DateRange {
+	var ranges []DateRange
+
+	for start.Before(end) {
+		windowEnd := start.Add(MaxDateRange)
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+
+		ranges = append(ranges, DateRange{Start: start, End: windowEnd})
+		start = windowEnd
+	}
+
+	return ranges
+}
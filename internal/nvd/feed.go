@@ -0,0 +1,176 @@
+package nvd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feedPattern はNVD JSON 2.0 データフィードのファイル名パターンです
+// (年ごとの nvdcve-2.0-YYYY.json.gz の他、modified / recent フィードも含みます)
+const feedPattern = "nvdcve-2.0-*.json.gz"
+
+// FeedMeta はデータフィードに付属する .meta ファイルの内容です
+type FeedMeta struct {
+	LastModifiedDate time.Time
+	// Size は展開後のJSONのサイズです
+	Size   int64
+	GzSize int64
+	// SHA256 は展開後のJSONのハッシュ値です
+	SHA256 string
+}
+
+// ReadFeedMeta は .meta ファイルを読み込みます
+func ReadFeedMeta(path string) (*FeedMeta, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open meta file: %w", err)
+	}
+	defer f.Close()
+
+	meta := &FeedMeta{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "lastModifiedDate":
+			meta.LastModifiedDate, err = time.Parse(time.RFC3339, value)
+		case "size":
+			meta.Size, err = strconv.ParseInt(value, 10, 64)
+		case "gzSize":
+			meta.GzSize, err = strconv.ParseInt(value, 10, 64)
+		case "sha256":
+			meta.SHA256 = strings.ToUpper(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in meta file %s: %w", key, path, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read meta file: %w", err)
+	}
+
+	if meta.SHA256 == "" {
+		return nil, fmt.Errorf("meta file %s has no sha256", path)
+	}
+
+	return meta, nil
+}
+
+// VerifyFeed はフィードを展開し、サイズとSHA-256が .meta の内容と一致するかを検証します
+func VerifyFeed(path string, meta *FeedMeta) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open feed: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to decompress feed: %w", err)
+	}
+	defer gz.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, gz)
+	if err != nil {
+		return fmt.Errorf("failed to decompress feed: %w", err)
+	}
+
+	if meta.Size > 0 && size != meta.Size {
+		return fmt.Errorf("size mismatch for %s: got %d, want %d", path, size, meta.Size)
+	}
+
+	if sum := strings.ToUpper(hex.EncodeToString(hash.Sum(nil))); sum != meta.SHA256 {
+		return fmt.Errorf("sha256 mismatch for %s: got %s, want %s", path, sum, meta.SHA256)
+	}
+
+	return nil
+}
+
+// feedMetaPath はフィードに対応する .meta ファイルのパスを返します
+func feedMetaPath(feedPath string) string {
+	return strings.TrimSuffix(feedPath, ".json.gz") + ".meta"
+}
+
+// ReadFeeds はディレクトリ内のデータフィードを順に読み込み、脆弱性を1件ずつ返すイテレーターです。
+// 各フィードは読み込む前に .meta のSHA-256で検証され、一致しない場合はエラーになります。
+func ReadFeeds(ctx context.Context, dir string) iter.Seq2[VulnerabilityItem, error] {
+	return func(yield func(VulnerabilityItem, error) bool) {
+		paths, err := filepath.Glob(filepath.Join(dir, feedPattern))
+		if err != nil {
+			yield(VulnerabilityItem{}, fmt.Errorf("failed to list feeds: %w", err))
+			return
+		}
+		if len(paths) == 0 {
+			yield(VulnerabilityItem{}, fmt.Errorf("no feeds matching %s found in %s", feedPattern, dir))
+			return
+		}
+
+		// 年ごとのフィードを古い順に読み、modified / recent は最後に読む
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			if err := readFeed(ctx, path, yield); err != nil {
+				if !errors.Is(err, errStopped) {
+					yield(VulnerabilityItem{}, fmt.Errorf("failed to read feed %s: %w", filepath.Base(path), err))
+				}
+				return
+			}
+		}
+	}
+}
+
+func readFeed(ctx context.Context, path string, yield func(VulnerabilityItem, error) bool) error {
+	meta, err := ReadFeedMeta(feedMetaPath(path))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Verifying feed %s...", filepath.Base(path))
+	if err := VerifyFeed(path, meta); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open feed: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to decompress feed: %w", err)
+	}
+	defer gz.Close()
+
+	log.Printf("Reading feed %s...", filepath.Base(path))
+	_, err = decodePage(gz, func(item VulnerabilityItem) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		return yield(item, nil)
+	})
+	if errors.Is(err, errStopped) && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
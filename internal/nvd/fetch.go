@@ -1,67 +1,275 @@
 package nvd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
-)
 
-const (
-	baseURL        = "https://services.nvd.nist.gov/rest/json/cves/2.0"
-	resultsPerPage = 100
+	"github.com/nexryai/eleos/internal/httpclient"
 )
 
-// FetchVulnerabilities は指定された期間のNVDデータを取得します
-func FetchVulnerabilities(pubStartDate, pubEndDate time.Time) (*[]VulnerabilityItem, error) {
-	return fetchVulnerabilitiesRecursive(pubStartDate, pubEndDate, 0)
+// requestTimeout はレスポンスヘッダーの受信と、ボディの1回の読み込みにかかる時間の上限です。
+// ボディ全体には適用しないため、呼び出し側が yield の中でDBへの書き込みなどに時間をかけてもタイムアウトしません。
+const requestTimeout = 60 * time.Second
+
+// errRequestTimeout はNVDからの応答が requestTimeout 以上途絶えたことを表します
+var errRequestTimeout = fmt.Errorf("no response from NVD within %s: %w", requestTimeout, context.DeadlineExceeded)
+
+// errStopped は呼び出し側がイテレーションを中断したことを表します
+var errStopped = errors.New("iteration stopped")
+
+// pageInfo はページのメタデータ (脆弱性の配列以外) を保持します
+type pageInfo struct {
+	ResultsPerPage int
+	StartIndex     int
+	TotalResults   int
+}
+
+// FetchVulnerabilities は指定された期間に公開された脆弱性を1件ずつ返すイテレーターです。
+// 各ページはストリーミングでデコードされるため、期間の長さに関わらずメモリ使用量は一定です。
+func (c *Client) FetchVulnerabilities(ctx context.Context, pubStartDate, pubEndDate time.Time) iter.Seq2[VulnerabilityItem, error] {
+	return c.fetchVulnerabilities(ctx, "pubStartDate", "pubEndDate", pubStartDate, pubEndDate)
+}
+
+// FetchModifiedVulnerabilities は指定された期間に更新された脆弱性を1件ずつ返すイテレーターです。
+// NVDの解析によってCPE設定やCVSSスコアが追加された既存の脆弱性もここに含まれます。
+func (c *Client) FetchModifiedVulnerabilities(ctx context.Context, lastModStartDate, lastModEndDate time.Time) iter.Seq2[VulnerabilityItem, error] {
+	return c.fetchVulnerabilities(ctx, "lastModStartDate", "lastModEndDate", lastModStartDate, lastModEndDate)
+}
+
+// ErrVulnerabilityNotFound は指定されたCVE IDの脆弱性がNVDに存在しないことを表します
+var ErrVulnerabilityNotFound = errors.New("vulnerability not found")
+
+// FetchVulnerability はCVE IDを指定して脆弱性を1件取得します
+func (c *Client) FetchVulnerability(ctx context.Context, cveID string) (*VulnerabilityItem, error) {
+	query := url.Values{}
+	query.Set("cveId", cveID)
+	pageURL := c.baseURL + "?" + query.Encode()
+
+	log.Printf("Fetching NVD data from URL: %s\n", pageURL)
+
+	var found *VulnerabilityItem
+	_, err := c.fetchPageWithRetry(ctx, pageURL, func(item VulnerabilityItem) bool {
+		found = &item
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", cveID, err)
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s: %w", cveID, ErrVulnerabilityNotFound)
+	}
+
+	return found, nil
 }
 
-func fetchVulnerabilitiesRecursive(pubStartDate, pubEndDate time.Time, startIndex int) (*[]VulnerabilityItem, error) {
-	url := fmt.Sprintf("%s?pubStartDate=%s&pubEndDate=%s&resultsPerPage=%d&startIndex=%d",
-		baseURL,
-		pubStartDate.Format(time.RFC3339),
-		pubEndDate.Format(time.RFC3339),
-		resultsPerPage,
-		startIndex,
-	)
+func (c *Client) fetchVulnerabilities(ctx context.Context, startParam, endParam string, startDate, endDate time.Time) iter.Seq2[VulnerabilityItem, error] {
+	return func(yield func(VulnerabilityItem, error) bool) {
+		startIndex := 0
+		for page := 1; ; page++ {
+			query := url.Values{}
+			query.Set(startParam, startDate.Format(time.RFC3339))
+			query.Set(endParam, endDate.Format(time.RFC3339))
+			query.Set("resultsPerPage", strconv.Itoa(c.pageSize))
+			query.Set("startIndex", strconv.Itoa(startIndex))
+			pageURL := c.baseURL + "?" + query.Encode()
 
-	log.Printf("Fetching NVD data from URL: %s\n", url)
+			log.Printf("Fetching NVD data from URL: %s\n", pageURL)
+
+			info, err := c.fetchPageWithRetry(ctx, pageURL, func(item VulnerabilityItem) bool {
+				return yield(item, nil)
+			})
+			if errors.Is(err, errStopped) {
+				return
+			}
+			if err != nil {
+				yield(VulnerabilityItem{}, fmt.Errorf("failed to fetch page %d (startIndex=%d): %w", page, startIndex, err))
+				return
+			}
+
+			// NVDは要求より少ない件数を返すことがあるため、実際に返された件数だけ進める
+			// (件数が0の場合は、それ以上進めないため終了する)
+			startIndex += info.ResultsPerPage
+			if info.ResultsPerPage == 0 || startIndex >= info.TotalResults {
+				return
+			}
+		}
+	}
+}
+
+// fetchPageWithRetry は一時的なエラーの場合にバックオフしながらリトライします。
+// ページの途中で失敗した場合、既に渡し終えた脆弱性はリトライ時に読み飛ばします。
+func (c *Client) fetchPageWithRetry(ctx context.Context, pageURL string, yield func(VulnerabilityItem) bool) (*pageInfo, error) {
+	delivered := 0
+
+	retryable := func(err error) bool {
+		return !errors.Is(err, errStopped) && httpclient.IsRetryable(ctx, err, temporaryStatus)
+	}
+	return httpclient.Retry(ctx, "NVD request", retryable, func() (*pageInfo, error) {
+		seen := 0
+		return c.fetchPage(ctx, pageURL, func(item VulnerabilityItem) bool {
+			seen++
+			if seen <= delivered {
+				return true
+			}
+			delivered++
+			return yield(item)
+		})
+	})
+}
+
+func (c *Client) fetchPage(ctx context.Context, pageURL string, yield func(VulnerabilityItem) bool) (*pageInfo, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limiter error: %w", err)
+	}
+
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.apiKey != "" {
+		req.Header.Set(apiKeyHeader, c.apiKey)
+	}
 
-	resp, err := http.Get(url)
+	timer := time.AfterFunc(requestTimeout, func() { cancel(errRequestTimeout) })
+	resp, err := c.httpClient.Do(req)
+	timer.Stop()
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch NVD data: %w", err)
+		return nil, fmt.Errorf("failed to fetch NVD data: %w", timeoutCause(reqCtx, err))
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		// エラー時のボディはHTMLなどのため読み捨てる
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		// NVDはエラー詳細を "message" ヘッダーで返す
+		return nil, httpclient.NewStatusError(resp, resp.Header.Get("message"))
+	}
+
+	body := &stallReader{r: resp.Body, cancel: cancel}
+	info, err := decodePage(body, yield)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, timeoutCause(reqCtx, err)
+	}
+	return info, nil
+}
+
+// stallReader は1回の読み込みが requestTimeout を超えた場合にリクエストを中断します。
+// 読み込みの合間 (yield の処理中) の時間は数えません。
+type stallReader struct {
+	r      io.Reader
+	cancel context.CancelCauseFunc
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	timer := time.AfterFunc(requestTimeout, func() { s.cancel(errRequestTimeout) })
+	defer timer.Stop()
+	return s.r.Read(p)
+}
+
+// timeoutCause はリクエストが応答の途絶によって中断された場合に、リトライ可能なタイムアウトのエラーを返します
+func timeoutCause(reqCtx context.Context, err error) error {
+	if errors.Is(err, errRequestTimeout) || errors.Is(err, errStopped) {
+		return err
+	}
+	if errors.Is(context.Cause(reqCtx), errRequestTimeout) {
+		return fmt.Errorf("%w: %v", errRequestTimeout, err)
 	}
+	return err
+}
+
+// decodePage はAPIレスポンスをストリーミングでデコードし、脆弱性を1件ずつ yield に渡します
+func decodePage(r io.Reader, yield func(VulnerabilityItem) bool) (*pageInfo, error) {
+	dec := json.NewDecoder(r)
+	info := &pageInfo{}
 
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
 	}
 
-	vulnerabilities := apiResp.Vulnerabilities
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v in response", tok)
+		}
 
-	// 残りのデータがある場合は再帰的に取得
-	if startIndex+resultsPerPage < apiResp.TotalResults {
-		nextVulnerabilities, err := fetchVulnerabilitiesRecursive(
-			pubStartDate,
-			pubEndDate,
-			startIndex+resultsPerPage,
-		)
-		
+		switch key {
+		case "resultsPerPage":
+			err = dec.Decode(&info.ResultsPerPage)
+		case "startIndex":
+			err = dec.Decode(&info.StartIndex)
+		case "totalResults":
+			err = dec.Decode(&info.TotalResults)
+		case "vulnerabilities":
+			err = decodeItems(dec, yield)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
 		if err != nil {
-			return nil, err
+			if errors.Is(err, errStopped) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to unmarshal response field %q: %w", key, err)
 		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func decodeItems(dec *json.Decoder, yield func(VulnerabilityItem) bool) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		var item VulnerabilityItem
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+		if !yield(item) {
+			return errStopped
+		}
+	}
+
+	return expectDelim(dec, ']')
+}
 
-		vulnerabilities = append(vulnerabilities, *nextVulnerabilities...)
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
 	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("unexpected token %v in response, want %v", tok, want)
+	}
+	return nil
+}
 
-	return &vulnerabilities, nil
+// temporaryStatus はリトライすべきステータスかどうかを返します。
+// NVDはレート制限超過時に403を返すため、403もリトライ対象に含めます。
+func temporaryStatus(statusCode int) bool {
+	return statusCode == http.StatusForbidden ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= 500
 }
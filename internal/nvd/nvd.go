@@ -1,6 +1,7 @@
 package nvd
 
 import (
+	"slices"
 	"strings"
 	"time"
 )
@@ -72,6 +73,7 @@ type Description struct {
 type Metrics struct {
 	CVSSMetricV40 []CVSSMetricV40 `json:"cvssMetricV40,omitempty"`
 	CVSSMetricV31 []CVSSMetricV31 `json:"cvssMetricV31,omitempty"`
+	CVSSMetricV30 []CVSSMetricV30 `json:"cvssMetricV30,omitempty"`
 	CVSSMetricV2  []CVSSMetricV2  `json:"cvssMetricV2,omitempty"`
 }
 
@@ -83,6 +85,30 @@ type CVSSMetricV31 struct {
 	ImpactScore         float64     `json:"impactScore"`
 }
 
+// CVSSMetricV30 と CVSSDataV30 は NVD の cvssMetricV30 をパースするための型です
+type CVSSMetricV30 struct {
+	Source              string      `json:"source"`
+	Type                string      `json:"type"`
+	CVSSData            CVSSDataV30 `json:"cvssData"`
+	ExploitabilityScore float64     `json:"exploitabilityScore"`
+	ImpactScore         float64     `json:"impactScore"`
+}
+
+type CVSSDataV30 struct {
+	Version               string  `json:"version"`
+	VectorString          string  `json:"vectorString"`
+	AttackVector          string  `json:"attackVector"`
+	AttackComplexity      string  `json:"attackComplexity"`
+	PrivilegesRequired    string  `json:"privilegesRequired"`
+	UserInteraction       string  `json:"userInteraction"`
+	Scope                 string  `json:"scope"`
+	ConfidentialityImpact string  `json:"confidentialityImpact"`
+	IntegrityImpact       string  `json:"integrityImpact"`
+	AvailabilityImpact    string  `json:"availabilityImpact"`
+	BaseScore             float64 `json:"baseScore"`
+	BaseSeverity          string  `json:"baseSeverity"`
+}
+
 // CVSSMetricV40 と CVSSDataV40 は NVD の cvssMetricV40 をパースするための型です
 type CVSSMetricV40 struct {
 	Source   string      `json:"source"`
@@ -177,6 +203,20 @@ type Weakness struct {
 	Description []Description `json:"description"`
 }
 
+// CWEs は脆弱性に付与されたCWE ID (CWE-79 など) を重複なしで返します。
+// NVD-CWE-Other や NVD-CWE-noinfo は含みません。
+func (c CVE) CWEs() []string {
+	var cwes []string
+	for _, weakness := range c.Weaknesses {
+		for _, desc := range weakness.Description {
+			if strings.HasPrefix(desc.Value, "CWE-") && !slices.Contains(cwes, desc.Value) {
+				cwes = append(cwes, desc.Value)
+			}
+		}
+	}
+	return cwes
+}
+
 type Configuration struct {
 	Operator string `json:"operator,omitempty"`
 	Nodes    []Node `json:"nodes"`
@@ -192,6 +232,8 @@ type CPEMatch struct {
 	Vulnerable            bool   `json:"vulnerable"`
 	Criteria              string `json:"criteria"`
 	VersionStartIncluding string `json:"versionStartIncluding,omitempty"`
+	VersionStartExcluding string `json:"versionStartExcluding,omitempty"`
+	VersionEndIncluding   string `json:"versionEndIncluding,omitempty"`
 	VersionEndExcluding   string `json:"versionEndExcluding,omitempty"`
 	MatchCriteriaID       string `json:"matchCriteriaId"`
 }
@@ -201,3 +243,65 @@ type Reference struct {
 	Source string   `json:"source"`
 	Tags   []string `json:"tags,omitempty"`
 }
+
+// Score は各バージョンのCVSSメトリクスから共通の項目を取り出したものです
+type Score struct {
+	Version      string
+	Source       string
+	Type         string
+	BaseScore    float64
+	VectorString string
+	BaseSeverity string
+}
+
+// Scores は全てのバージョン・提供元のCVSSスコアを返します
+func (m Metrics) Scores() []Score {
+	scores := make([]Score, 0, len(m.CVSSMetricV40)+len(m.CVSSMetricV31)+len(m.CVSSMetricV30)+len(m.CVSSMetricV2))
+
+	for _, metric := range m.CVSSMetricV40 {
+		scores = append(scores, Score{
+			Version:      "4.0",
+			Source:       metric.Source,
+			Type:         metric.Type,
+			BaseScore:    metric.CVSSData.BaseScore,
+			VectorString: metric.CVSSData.VectorString,
+			BaseSeverity: metric.CVSSData.BaseSeverity,
+		})
+	}
+
+	for _, metric := range m.CVSSMetricV31 {
+		scores = append(scores, Score{
+			Version:      "3.1",
+			Source:       metric.Source,
+			Type:         metric.Type,
+			BaseScore:    metric.CVSSData.BaseScore,
+			VectorString: metric.CVSSData.VectorString,
+			BaseSeverity: metric.CVSSData.BaseSeverity,
+		})
+	}
+
+	for _, metric := range m.CVSSMetricV30 {
+		scores = append(scores, Score{
+			Version:      "3.0",
+			Source:       metric.Source,
+			Type:         metric.Type,
+			BaseScore:    metric.CVSSData.BaseScore,
+			VectorString: metric.CVSSData.VectorString,
+			BaseSeverity: metric.CVSSData.BaseSeverity,
+		})
+	}
+
+	for _, metric := range m.CVSSMetricV2 {
+		// V2の重大度は cvssData ではなくメトリクス側にある
+		scores = append(scores, Score{
+			Version:      "2.0",
+			Source:       metric.Source,
+			Type:         metric.Type,
+			BaseScore:    metric.CVSSData.BaseScore,
+			VectorString: metric.CVSSData.VectorString,
+			BaseSeverity: metric.BaseSeverity,
+		})
+	}
+
+	return scores
+}
@@ -0,0 +1,35 @@
+package nvd
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// NVD API のレート制限 (ローリング30秒間あたりのリクエスト数)
+// https://nvd.nist.gov/developers/start-here#divRateLimits
+const (
+	rateLimitWindow = 30 * time.Second
+	publicRateLimit = 5
+	apiKeyRateLimit = 50
+)
+
+// レート制限はAPIキーの有無ごとにプロセス内の全てのクライアント・リクエスト
+// (ページングの後続リクエストを含む) で共有されます
+var (
+	publicLimiter = newLimiter(publicRateLimit)
+	apiKeyLimiter = newLimiter(apiKeyRateLimit)
+)
+
+// newLimiter は30秒あたりのリクエスト数からトークンバケットを生成します。
+// ローリングウィンドウ内の上限を超えないよう、バーストは1に制限します。
+func newLimiter(requests int) *rate.Limiter {
+	return rate.NewLimiter(rate.Every(rateLimitWindow/time.Duration(requests)), 1)
+}
+
+func sharedLimiter(hasAPIKey bool) *rate.Limiter {
+	if hasAPIKey {
+		return apiKeyLimiter
+	}
+	return publicLimiter
+}
@@ -0,0 +1,52 @@
+package nvd
+
+import (
+	"github.com/nexryai/eleos/internal/cpe"
+	"github.com/nexryai/eleos/internal/version"
+)
+
+// VersionRange はCPEMatchが対象とするバージョンの範囲です。
+// criteria のバージョン欄に具体的な値がある場合は Exact に、
+// versionStart* / versionEnd* で範囲が指定されている場合は Start / End に値が入ります。
+type VersionRange = version.Range
+
+// VersionRange は criteria と4種類のバージョン境界から範囲を組み立てます
+func (m CPEMatch) VersionRange() VersionRange {
+	r := VersionRange{
+		Exact: criteriaVersion(m.Criteria),
+	}
+
+	switch {
+	case m.VersionStartIncluding != "":
+		r.Start, r.StartInclusive = m.VersionStartIncluding, true
+	case m.VersionStartExcluding != "":
+		r.Start = m.VersionStartExcluding
+	}
+
+	switch {
+	case m.VersionEndIncluding != "":
+		r.End, r.EndInclusive = m.VersionEndIncluding, true
+	case m.VersionEndExcluding != "":
+		r.End = m.VersionEndExcluding
+	}
+
+	return r
+}
+
+// criteriaVersion はCPE 2.3形式の文字列からバージョン (とアップデート) を取り出します。
+// ANY (*) や NA (-) の場合、または解析できない場合は空文字列を返します。
+func criteriaVersion(criteria string) string {
+	name, err := cpe.Parse(criteria)
+	if err != nil {
+		return ""
+	}
+
+	v, update := name.Get(cpe.Version), name.Get(cpe.Update)
+	if v.Kind != cpe.Literal {
+		return ""
+	}
+	if update.Kind == cpe.Literal {
+		return v.Unquoted() + "-" + update.Unquoted()
+	}
+	return v.Unquoted()
+}
@@ -0,0 +1,165 @@
+package osv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nexryai/eleos/internal/httpclient"
+	"github.com/nexryai/eleos/internal/purl"
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultBaseURL = "https://api.osv.dev/v1"
+
+	requestTimeout = 60 * time.Second
+	// maxErrorBodySize はエラーに含めるレスポンスボディの最大サイズです
+	maxErrorBodySize = 1024
+
+	// OSV APIに明示的なレート制限はないが、1秒あたりのリクエスト数を抑える
+	requestsPerSecond = 10
+)
+
+// Client はOSV APIのクライアントです
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	limiter    *rate.Limiter
+}
+
+type Option func(*Client)
+
+// WithBaseURL はAPIのエンドポイントを変更します
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithHTTPClient はリクエストに使う http.Client を変更します
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: &http.Client{Timeout: requestTimeout},
+		userAgent:  httpclient.DefaultUserAgent,
+		limiter:    rate.NewLimiter(rate.Limit(requestsPerSecond), 1),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+type queryRequest struct {
+	Package   queryPackage `json:"package"`
+	PageToken string       `json:"page_token,omitempty"`
+}
+
+type queryPackage struct {
+	PURL string `json:"purl"`
+}
+
+type queryResponse struct {
+	Vulns         []Vulnerability `json:"vulns"`
+	NextPageToken string          `json:"next_page_token"`
+}
+
+// QueryPackage はパッケージの全てのバージョンに影響する脆弱性を1件ずつ返すイテレーターです
+func (c *Client) QueryPackage(ctx context.Context, pkg purl.PackageURL) iter.Seq2[Vulnerability, error] {
+	return func(yield func(Vulnerability, error) bool) {
+		request := queryRequest{Package: queryPackage{PURL: pkg.Package().String()}}
+
+		for {
+			page, err := c.queryWithRetry(ctx, request)
+			if err != nil {
+				yield(Vulnerability{}, fmt.Errorf("failed to query OSV for %s: %w", pkg, err))
+				return
+			}
+
+			for _, vuln := range page.Vulns {
+				if !yield(vuln, nil) {
+					return
+				}
+			}
+
+			if page.NextPageToken == "" {
+				return
+			}
+			request.PageToken = page.NextPageToken
+		}
+	}
+}
+
+// queryWithRetry は一時的なエラーの場合にバックオフしながらリトライします
+func (c *Client) queryWithRetry(ctx context.Context, request queryRequest) (*queryResponse, error) {
+	retryable := func(err error) bool {
+		return httpclient.IsRetryable(ctx, err, temporaryStatus)
+	}
+	return httpclient.Retry(ctx, "OSV query", retryable, func() (*queryResponse, error) {
+		return c.query(ctx, request)
+	})
+}
+
+func (c *Client) query(ctx context.Context, request queryRequest) (*queryResponse, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limiter error: %w", err)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OSV data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// OSVはエラー詳細をボディ (JSON) で返す
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return nil, httpclient.NewStatusError(resp, strings.TrimSpace(string(body)))
+	}
+
+	var page queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode OSV response: %w", err)
+	}
+
+	return &page, nil
+}
+
+// temporaryStatus はリトライすべきステータスかどうかを返します
+func temporaryStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
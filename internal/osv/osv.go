@@ -0,0 +1,160 @@
+// Package osv はOSV (https://osv.dev) からパッケージの脆弱性を取得します。
+// NVDのCPEでは表せないライブラリやパッケージの脆弱性を、パッケージURLで照合するために使います。
+package osv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nexryai/eleos/internal/purl"
+	"github.com/nexryai/eleos/internal/version"
+)
+
+// Vulnerability はOSVスキーマの脆弱性です (使用する項目のみ)
+// https://ossf.github.io/osv-schema/
+type Vulnerability struct {
+	ID        string     `json:"id"`
+	Summary   string     `json:"summary"`
+	Details   string     `json:"details"`
+	Aliases   []string   `json:"aliases"`
+	Modified  time.Time  `json:"modified"`
+	Published time.Time  `json:"published"`
+	Withdrawn *time.Time `json:"withdrawn,omitempty"`
+	Affected  []Affected `json:"affected"`
+}
+
+type Affected struct {
+	Package  Package  `json:"package"`
+	Ranges   []Range  `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	PURL      string `json:"purl,omitempty"`
+}
+
+type Range struct {
+	// Type は SEMVER, ECOSYSTEM, GIT のいずれかです
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// ecosystemTypes はOSVのエコシステムとパッケージURLの種類の対応です
+var ecosystemTypes = map[string]string{
+	"Go":        "golang",
+	"npm":       "npm",
+	"PyPI":      "pypi",
+	"crates.io": "cargo",
+	"Maven":     "maven",
+	"RubyGems":  "gem",
+	"NuGet":     "nuget",
+	"Packagist": "composer",
+	"Pub":       "pub",
+	"Hex":       "hex",
+}
+
+// PackageURL は影響を受けるパッケージのURLを返します。
+// purl が含まれていない場合は、エコシステムと名前から組み立てます。
+func (p Package) PackageURL() (purl.PackageURL, error) {
+	if p.PURL != "" {
+		u, err := purl.Parse(p.PURL)
+		if err != nil {
+			return purl.PackageURL{}, err
+		}
+		return u.Package(), nil
+	}
+
+	// "Debian:12" のようにバージョンが付くエコシステムもある
+	ecosystem, _, _ := strings.Cut(p.Ecosystem, ":")
+	packageType, ok := ecosystemTypes[ecosystem]
+	if !ok {
+		return purl.PackageURL{}, fmt.Errorf("unsupported ecosystem %q", p.Ecosystem)
+	}
+
+	name := p.Name
+	if packageType == "maven" {
+		// Maven の名前は "groupId:artifactId"
+		name = strings.Replace(name, ":", "/", 1)
+	}
+
+	return purl.Parse("pkg:" + packageType + "/" + name)
+}
+
+// VersionRanges は影響を受けるバージョンを範囲の一覧に変換します。
+// GIT の範囲はコミットハッシュのため扱いません。範囲が1つもない場合は空のスライスを返します。
+func (a Affected) VersionRanges() []version.Range {
+	var ranges []version.Range
+
+	for _, v := range a.Versions {
+		ranges = append(ranges, version.Range{Exact: v})
+	}
+
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+
+		// introduced から次の fixed / last_affected までが1つの範囲になる
+		var current *version.Range
+		for _, event := range r.Events {
+			switch {
+			case event.Introduced != "":
+				current = &version.Range{StartInclusive: true}
+				if event.Introduced != "0" {
+					current.Start = event.Introduced
+				}
+			case current != nil && event.Fixed != "":
+				current.End = event.Fixed
+				ranges = append(ranges, *current)
+				current = nil
+			case current != nil && event.LastAffected != "":
+				current.End, current.EndInclusive = event.LastAffected, true
+				ranges = append(ranges, *current)
+				current = nil
+			}
+		}
+
+		// 修正されていない範囲
+		if current != nil {
+			ranges = append(ranges, *current)
+		}
+	}
+
+	return ranges
+}
+
+// CVE はこの脆弱性に対応するCVE IDを返します。ない場合は空文字列を返します。
+func (v Vulnerability) CVE() string {
+	if strings.HasPrefix(v.ID, "CVE-") {
+		return v.ID
+	}
+	for _, alias := range v.Aliases {
+		if strings.HasPrefix(alias, "CVE-") {
+			return alias
+		}
+	}
+	return ""
+}
+
+// GHSA はこの脆弱性に対応するGitHub Security AdvisoryのIDを返します。ない場合は空文字列を返します。
+func (v Vulnerability) GHSA() string {
+	if strings.HasPrefix(v.ID, "GHSA-") {
+		return v.ID
+	}
+	for _, alias := range v.Aliases {
+		if strings.HasPrefix(alias, "GHSA-") {
+			return alias
+		}
+	}
+	return ""
+}
@@ -0,0 +1,333 @@
+package product
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nexryai/eleos/internal/cpe"
+	"github.com/nexryai/eleos/internal/purl"
+	"github.com/nexryai/eleos/internal/version"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// defaultDefinitions は設定ファイルが指定されていない場合に使う製品定義です
+//
+//go:embed defaults.json
+var defaultDefinitions []byte
+
+// Config は製品定義ファイルの内容です
+type Config struct {
+	Products []*Definition `json:"products"`
+}
+
+// Definition は監視対象の製品の定義です
+type Definition struct {
+	Name string `json:"name"`
+	// ID は products コレクションのドキュメントのIDです
+	ID string `json:"id"`
+	// CPEs は製品に該当するCPE名のパターンです (形式化文字列またはURI)
+	CPEs []string `json:"cpes"`
+	// Exclude は CPEs に該当しても製品に含めないCPE名のパターンです
+	Exclude []string `json:"exclude,omitempty"`
+	// VersionScheme はバージョンの比較方法です (dotted, windows-build, semver)
+	VersionScheme string `json:"versionScheme,omitempty"`
+	// Versions は運用中のバージョン ("6.1.55") またはその範囲 (">=6.1, <6.2") です。
+	// 空の場合は全てのバージョンを対象とします。
+	Versions []string `json:"versions,omitempty"`
+	// Tentative は設定 (CPE) がまだないCVEを暫定的に紐づけるための条件です (省略可)
+	Tentative *Tentative `json:"tentative,omitempty"`
+	// Packages は製品に該当するパッケージです (CPEで表せないライブラリなど)
+	Packages []*Package `json:"packages,omitempty"`
+	// Suppressions は製品に該当しないことが分かっている脆弱性を除外するルールです
+	Suppressions []*Suppression `json:"suppressions,omitempty"`
+
+	patterns   []cpe.Name
+	exclusions []cpe.Name
+	versions   []version.Range
+	compare    version.Comparator
+	tentative  []*regexp.Regexp
+}
+
+// Package はパッケージURLによる照合条件です
+type Package struct {
+	// Purl はバージョンを含まないパッケージURLです (pkg:golang/golang.org/x/net など)
+	Purl string `json:"purl"`
+	// VersionScheme はバージョンの比較方法です (省略時は semver)
+	VersionScheme string `json:"versionScheme,omitempty"`
+	// Versions は使用中のバージョン ("0.23.0") またはその範囲 (">=0.20.0, <0.30.0") です。
+	// 空の場合は全てのバージョンを対象とします。
+	Versions []string `json:"versions,omitempty"`
+
+	purl     purl.PackageURL
+	versions []version.Range
+	compare  version.Comparator
+}
+
+func (p *Package) compile() error {
+	var err error
+	if p.purl, err = purl.Parse(p.Purl); err != nil {
+		return err
+	}
+	if p.purl.Version != "" {
+		return fmt.Errorf("purl %s must not contain a version (use versions instead)", p.Purl)
+	}
+
+	scheme := p.VersionScheme
+	if scheme == "" {
+		scheme = "semver"
+	}
+	if p.compare, err = version.ComparatorFor(scheme); err != nil {
+		return err
+	}
+
+	p.versions = make([]version.Range, 0, len(p.Versions))
+	for _, v := range p.Versions {
+		r, err := version.ParseRange(v)
+		if err != nil {
+			return fmt.Errorf("purl %s: %w", p.Purl, err)
+		}
+		p.versions = append(p.versions, r)
+	}
+
+	return nil
+}
+
+// affects は使用中のいずれかのバージョンが影響を受ける範囲に含まれるかどうかを返します
+func (p *Package) affects(affected []version.Range) bool {
+	if len(p.versions) == 0 || len(affected) == 0 {
+		return true
+	}
+
+	for _, used := range p.versions {
+		for _, r := range affected {
+			if r.Overlaps(used, p.compare) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Tentative は説明文とCNAの識別子による暫定的な照合条件です
+type Tentative struct {
+	// Sources はCVEの sourceIdentifier (CNAのメールアドレスやUUID) です
+	Sources []string `json:"sources,omitempty"`
+	// Keywords は説明文に含まれる語句です (大文字と小文字を区別しません)
+	Keywords []string `json:"keywords,omitempty"`
+	// Patterns は説明文に対する正規表現です
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// Compile は定義を検証し、照合に使う形式に変換します。
+// Load で読み込んだ定義は変換済みのため、他の形式から作った定義にのみ使います。
+func (d *Definition) Compile() error {
+	if d.Name == "" {
+		return fmt.Errorf("product name is required")
+	}
+	if _, err := bson.ObjectIDFromHex(d.ID); err != nil {
+		return fmt.Errorf("product %s: invalid id %q: %w", d.Name, d.ID, err)
+	}
+	if len(d.CPEs) == 0 && len(d.Packages) == 0 {
+		return fmt.Errorf("product %s: at least one CPE pattern or package is required", d.Name)
+	}
+
+	var err error
+	if d.patterns, err = parsePatterns(d.CPEs); err != nil {
+		return fmt.Errorf("product %s: %w", d.Name, err)
+	}
+	if d.exclusions, err = parsePatterns(d.Exclude); err != nil {
+		return fmt.Errorf("product %s: %w", d.Name, err)
+	}
+	if d.compare, err = version.ComparatorFor(d.VersionScheme); err != nil {
+		return fmt.Errorf("product %s: %w", d.Name, err)
+	}
+
+	d.versions = make([]version.Range, 0, len(d.Versions))
+	for _, v := range d.Versions {
+		r, err := version.ParseRange(v)
+		if err != nil {
+			return fmt.Errorf("product %s: %w", d.Name, err)
+		}
+		d.versions = append(d.versions, r)
+	}
+
+	for _, p := range d.Packages {
+		if err := p.compile(); err != nil {
+			return fmt.Errorf("product %s: %w", d.Name, err)
+		}
+	}
+
+	for _, suppression := range d.Suppressions {
+		if err := suppression.compile(); err != nil {
+			return fmt.Errorf("product %s: %w", d.Name, err)
+		}
+	}
+
+	if d.Tentative != nil {
+		d.tentative = make([]*regexp.Regexp, 0, len(d.Tentative.Patterns))
+		for _, pattern := range d.Tentative.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("product %s: invalid tentative pattern: %w", d.Name, err)
+			}
+			d.tentative = append(d.tentative, re)
+		}
+	}
+
+	return nil
+}
+
+func parsePatterns(patterns []string) ([]cpe.Name, error) {
+	names := make([]cpe.Name, 0, len(patterns))
+	for _, pattern := range patterns {
+		name, err := cpe.Parse(pattern)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (d *Definition) UUID() string {
+	return d.ID
+}
+
+// MatchName は解析済みのCPE名に該当した製品のCPEパターンを返します
+func (d *Definition) MatchName(name cpe.Name) (string, bool) {
+	i, ok := firstMatch(name, d.patterns...)
+	if !ok || excludedBy(name, d.exclusions...) {
+		return "", false
+	}
+	return d.CPEs[i], true
+}
+
+// Patterns は製品に該当するCPE名のパターンを返します (照合の索引に使います)
+func (d *Definition) Patterns() []cpe.Name {
+	return d.patterns
+}
+
+// PackageURLs は製品に該当するパッケージのURLを返します
+func (d *Definition) PackageURLs() []purl.PackageURL {
+	urls := make([]purl.PackageURL, 0, len(d.Packages))
+	for _, p := range d.Packages {
+		urls = append(urls, p.purl)
+	}
+	return urls
+}
+
+// MatchPackage はパッケージと影響を受けるバージョンの範囲が製品に該当するかどうかを返します。
+// 該当した場合は、マッチした製品のパッケージURLを返します。
+func (d *Definition) MatchPackage(pkg purl.PackageURL, affected []version.Range) (string, bool) {
+	for _, p := range d.Packages {
+		if p.purl.SamePackage(pkg) && p.affects(affected) {
+			return p.Purl, true
+		}
+	}
+	return "", false
+}
+
+// Suppression は脆弱性に適用される最初の抑制ルールを返します。適用されるルールがない場合は nil を返します。
+// criteria は製品にマッチしたCPEです (パッケージURLでマッチした場合などは空になります)。
+func (d *Definition) Suppression(cveID string, cwes, criteria []string, now time.Time) *Suppression {
+	for _, s := range d.Suppressions {
+		if s.applies(cveID, cwes, criteria, now) {
+			return s
+		}
+	}
+	return nil
+}
+
+// HasSuppressions は抑制ルールが1つ以上あるかどうかを返します
+func (d *Definition) HasSuppressions() bool {
+	return len(d.Suppressions) > 0
+}
+
+// MatchTentative はCVEの sourceIdentifier と説明文が暫定的な照合条件に該当するかどうかを返します。
+// 該当した場合は、どの条件に該当したかを返します。
+func (d *Definition) MatchTentative(sourceIdentifier, description string) (string, bool) {
+	if d.Tentative == nil {
+		return "", false
+	}
+
+	for _, source := range d.Tentative.Sources {
+		if strings.EqualFold(source, sourceIdentifier) {
+			return "source:" + source, true
+		}
+	}
+
+	lower := strings.ToLower(description)
+	for _, keyword := range d.Tentative.Keywords {
+		if strings.Contains(lower, strings.ToLower(keyword)) {
+			return "keyword:" + keyword, true
+		}
+	}
+
+	for _, re := range d.tentative {
+		if re.MatchString(description) {
+			return "pattern:" + re.String(), true
+		}
+	}
+
+	return "", false
+}
+
+func (d *Definition) DeployedVersions() []version.Range {
+	return d.versions
+}
+
+func (d *Definition) CompareVersions(a, b string) int {
+	return d.compare(a, b)
+}
+
+// Load は製品定義をJSONから読み込みます
+func Load(r io.Reader) ([]*Definition, error) {
+	var config Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse product definitions: %w", err)
+	}
+
+	seen := make(map[string]struct{}, len(config.Products))
+	for _, d := range config.Products {
+		if err := d.Compile(); err != nil {
+			return nil, err
+		}
+
+		if _, ok := seen[d.ID]; ok {
+			return nil, fmt.Errorf("duplicate product id %s", d.ID)
+		}
+		seen[d.ID] = struct{}{}
+	}
+
+	return config.Products, nil
+}
+
+// LoadFile は製品定義ファイルを読み込みます
+func LoadFile(path string) ([]*Definition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open product definitions: %w", err)
+	}
+	defer f.Close()
+
+	return Load(f)
+}
+
+// Defaults は組み込みの製品定義 (Linux, Windows) を返します
+func Defaults() []*Definition {
+	definitions, err := Load(bytes.NewReader(defaultDefinitions))
+	if err != nil {
+		panic(fmt.Sprintf("invalid default product definitions: %v", err))
+	}
+	return definitions
+}
@@ -0,0 +1,25 @@
+package product
+
+import "github.com/nexryai/eleos/internal/cpe"
+
+// excludedBy は name がいずれかの除外パターンに完全に含まれる (SUPERSET) かどうかを返します。
+// 一部だけが重なる criteria (バージョンが ANY の範囲指定など) は除外しません。
+func excludedBy(name cpe.Name, exclusions ...cpe.Name) bool {
+	for _, exclusion := range exclusions {
+		if cpe.IsSuperset(exclusion, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstMatch は name と共通するCPE名を持つ最初のパターンの位置を返します
+func firstMatch(name cpe.Name, patterns ...cpe.Name) (int, bool) {
+	for i, pattern := range patterns {
+		if !cpe.IsDisjoint(pattern, name) {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
@@ -0,0 +1,91 @@
+package product
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nexryai/eleos/internal/cpe"
+)
+
+// Suppression は脆弱性を製品に該当しない (not affected) ものとして扱うルールです。
+// 指定された条件 (CVE ID, CWE, CPEパターン) を全て満たすマッチに適用されます。
+type Suppression struct {
+	CVE string `json:"cve,omitempty"`
+	CWE string `json:"cwe,omitempty"`
+	// CPE はマッチしたCPEに対するパターンです (マッチした全てのCPEがパターンに含まれる場合に適用されます)
+	CPE string `json:"cpe,omitempty"`
+	// ExpiresAt を過ぎたルールは適用されなくなります (省略時は無期限)
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Justification は該当しない理由です
+	Justification string `json:"justification"`
+
+	pattern *cpe.Name
+}
+
+func (s *Suppression) compile() error {
+	if s.CVE == "" && s.CWE == "" && s.CPE == "" {
+		return fmt.Errorf("suppression requires at least one of cve, cwe or cpe")
+	}
+	if s.Justification == "" {
+		return fmt.Errorf("suppression %s: justification is required", s)
+	}
+
+	if s.CPE != "" {
+		pattern, err := cpe.Parse(s.CPE)
+		if err != nil {
+			return fmt.Errorf("suppression %s: %w", s, err)
+		}
+		s.pattern = &pattern
+	}
+
+	return nil
+}
+
+// applies はルールがマッチに適用されるかどうかを返します
+func (s *Suppression) applies(cveID string, cwes, criteria []string, now time.Time) bool {
+	if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+		return false
+	}
+	if s.CVE != "" && !strings.EqualFold(s.CVE, cveID) {
+		return false
+	}
+	if s.CWE != "" && !slices.ContainsFunc(cwes, func(cwe string) bool { return strings.EqualFold(s.CWE, cwe) }) {
+		return false
+	}
+
+	if s.pattern != nil {
+		// CPEでマッチしていない場合や、パターンに含まれないCPEでもマッチしている場合は適用しない
+		// (一部が重なるだけのCPEは、パターン外のバージョンなどを含むため抑制しない)
+		if len(criteria) == 0 {
+			return false
+		}
+		for _, c := range criteria {
+			name, err := cpe.Parse(c)
+			if err != nil || !cpe.IsSuperset(*s.pattern, name) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// String はルールの条件を "cve:CVE-2024-1234, cwe:CWE-79" のような形式で返します
+func (s *Suppression) String() string {
+	var conditions []string
+	if s.CVE != "" {
+		conditions = append(conditions, "cve:"+s.CVE)
+	}
+	if s.CWE != "" {
+		conditions = append(conditions, "cwe:"+s.CWE)
+	}
+	if s.CPE != "" {
+		conditions = append(conditions, "cpe:"+s.CPE)
+	}
+	if s.ExpiresAt != nil {
+		conditions = append(conditions, "until:"+s.ExpiresAt.Format(time.DateOnly))
+	}
+	return strings.Join(conditions, ", ")
+}
@@ -0,0 +1,163 @@
+// Package purl はパッケージURL (pkg:type/namespace/name@version?qualifiers#subpath) を解析します。
+// https://github.com/package-url/purl-spec
+package purl
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// PackageURL はパッケージURLの各要素です
+type PackageURL struct {
+	Type       string
+	Namespace  string
+	Name       string
+	Version    string
+	Qualifiers map[string]string
+	Subpath    string
+}
+
+// Parse はパッケージURLを解析し、種類ごとの規則に従って正規化します
+func Parse(s string) (PackageURL, error) {
+	rest, ok := strings.CutPrefix(s, "pkg:")
+	if !ok {
+		return PackageURL{}, fmt.Errorf("invalid purl %q: must start with pkg:", s)
+	}
+	rest = strings.TrimLeft(rest, "/")
+
+	var p PackageURL
+	var err error
+
+	if before, subpath, found := strings.Cut(rest, "#"); found {
+		rest = before
+		if p.Subpath, err = url.PathUnescape(strings.Trim(subpath, "/")); err != nil {
+			return PackageURL{}, fmt.Errorf("invalid purl %q: %w", s, err)
+		}
+	}
+
+	if before, query, found := strings.Cut(rest, "?"); found {
+		rest = before
+		if p.Qualifiers, err = parseQualifiers(query); err != nil {
+			return PackageURL{}, fmt.Errorf("invalid purl %q: %w", s, err)
+		}
+	}
+
+	// バージョンの区切りは最後の '@' (npm のスコープの '@' はエンコードされているとは限らない)
+	if i := strings.LastIndex(rest, "@"); i > strings.LastIndex(rest, "/") {
+		if p.Version, err = url.PathUnescape(rest[i+1:]); err != nil {
+			return PackageURL{}, fmt.Errorf("invalid purl %q: %w", s, err)
+		}
+		rest = rest[:i]
+	}
+
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	if len(segments) < 2 || segments[0] == "" {
+		return PackageURL{}, fmt.Errorf("invalid purl %q: type and name are required", s)
+	}
+
+	p.Type = strings.ToLower(segments[0])
+	for i, segment := range segments[1:] {
+		if segments[i+1], err = url.PathUnescape(segment); err != nil {
+			return PackageURL{}, fmt.Errorf("invalid purl %q: %w", s, err)
+		}
+	}
+	p.Name = segments[len(segments)-1]
+	p.Namespace = strings.Join(segments[1:len(segments)-1], "/")
+
+	if p.Name == "" {
+		return PackageURL{}, fmt.Errorf("invalid purl %q: name is required", s)
+	}
+
+	p.normalize()
+	return p, nil
+}
+
+func parseQualifiers(query string) (map[string]string, error) {
+	qualifiers := make(map[string]string)
+	for _, pair := range strings.Split(query, "&") {
+		key, value, _ := strings.Cut(pair, "=")
+		if key == "" || value == "" {
+			continue
+		}
+
+		decoded, err := url.PathUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		qualifiers[strings.ToLower(key)] = decoded
+	}
+	return qualifiers, nil
+}
+
+// normalize は大文字と小文字を区別しない種類の名前などを正規化します
+func (p *PackageURL) normalize() {
+	switch p.Type {
+	case "pypi":
+		p.Name = strings.ReplaceAll(strings.ToLower(p.Name), "_", "-")
+	case "npm", "github", "bitbucket", "composer", "nuget":
+		p.Namespace = strings.ToLower(p.Namespace)
+		p.Name = strings.ToLower(p.Name)
+	}
+}
+
+// Package はバージョン・修飾子・サブパスを除いた、パッケージ自体を表すURLを返します
+func (p PackageURL) Package() PackageURL {
+	return PackageURL{Type: p.Type, Namespace: p.Namespace, Name: p.Name}
+}
+
+// SamePackage は2つのURLが同じパッケージを指しているかどうかを返します
+func (p PackageURL) SamePackage(other PackageURL) bool {
+	return p.Type == other.Type && p.Namespace == other.Namespace && p.Name == other.Name
+}
+
+// String はパッケージURLの文字列に変換します
+func (p PackageURL) String() string {
+	var b strings.Builder
+	b.WriteString("pkg:")
+	b.WriteString(p.Type)
+
+	if p.Namespace != "" {
+		for _, segment := range strings.Split(p.Namespace, "/") {
+			b.WriteByte('/')
+			b.WriteString(escape(segment))
+		}
+	}
+
+	b.WriteByte('/')
+	b.WriteString(escape(p.Name))
+
+	if p.Version != "" {
+		b.WriteByte('@')
+		b.WriteString(escape(p.Version))
+	}
+
+	if len(p.Qualifiers) > 0 {
+		keys := make([]string, 0, len(p.Qualifiers))
+		for key := range p.Qualifiers {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for i, key := range keys {
+			if i == 0 {
+				b.WriteByte('?')
+			} else {
+				b.WriteByte('&')
+			}
+			b.WriteString(key + "=" + escape(p.Qualifiers[key]))
+		}
+	}
+
+	if p.Subpath != "" {
+		b.WriteString("#" + p.Subpath)
+	}
+
+	return b.String()
+}
+
+// escape は要素をパーセントエンコードします (':' はエンコードせず、'@' はエンコードします)
+func escape(s string) string {
+	return strings.NewReplacer("%3A", ":", "@", "%40").Replace(url.PathEscape(s))
+}
@@ -0,0 +1,158 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Range はバージョンの範囲です。
+// Exact に値がある場合はそのバージョンのみ、Start / End がある場合はその範囲を表します。
+// 全て空の場合は全てのバージョンを表します。
+type Range struct {
+	Exact          string
+	Start          string
+	StartInclusive bool
+	End            string
+	EndInclusive   bool
+}
+
+// ParseRange は "6.1.55" のようなバージョン、または ">=6.1, <6.2" のような制約を解析します。
+// "*" または空文字列は全てのバージョンを表します。
+func ParseRange(s string) (Range, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" {
+		return Range{}, nil
+	}
+
+	var r Range
+	for _, term := range strings.Split(s, ",") {
+		operator, operand := splitOperator(strings.TrimSpace(term))
+		if operand == "" || strings.ContainsAny(operand, " \t") {
+			return Range{}, fmt.Errorf("invalid version constraint %q: %q must be an operator followed by a version", s, term)
+		}
+
+		switch operator {
+		case ">=":
+			r.Start, r.StartInclusive = operand, true
+		case ">":
+			r.Start = operand
+		case "<=":
+			r.End, r.EndInclusive = operand, true
+		case "<":
+			r.End = operand
+		default:
+			r.Exact = operand
+		}
+	}
+
+	if r.Exact == "" && r.Start == "" && r.End == "" {
+		return Range{}, fmt.Errorf("invalid version constraint %q", s)
+	}
+
+	return r, nil
+}
+
+// splitOperator は制約の項を演算子 (">=", ">", "<=", "<", "=" または空) とバージョンに分けます
+func splitOperator(term string) (string, string) {
+	for _, operator := range []string{">=", "<=", ">", "<", "="} {
+		if operand, ok := strings.CutPrefix(term, operator); ok {
+			return operator, strings.TrimSpace(operand)
+		}
+	}
+	return "", term
+}
+
+// IsAny は全てのバージョンが対象かどうかを返します
+func (r Range) IsAny() bool {
+	return r.Exact == "" && !r.IsBounded()
+}
+
+// IsBounded は開始・終了のいずれかの境界が指定されているかどうかを返します
+func (r Range) IsBounded() bool {
+	return r.Start != "" || r.End != ""
+}
+
+// Contains は version が範囲に含まれるかどうかを返します
+func (r Range) Contains(version string, compare Comparator) bool {
+	return r.Overlaps(Range{Exact: version}, compare)
+}
+
+// Overlaps は2つの範囲に共通するバージョンが存在しうるかどうかを返します
+func (r Range) Overlaps(other Range, compare Comparator) bool {
+	if r.IsAny() || other.IsAny() {
+		return true
+	}
+
+	if r.Exact != "" && other.Exact != "" && compare(r.Exact, other.Exact) != 0 {
+		return false
+	}
+
+	lower, lowerInclusive := r.lower()
+	otherLower, otherLowerInclusive := other.lower()
+	upper, upperInclusive := r.upper()
+	otherUpper, otherUpperInclusive := other.upper()
+
+	return below(lower, lowerInclusive, otherUpper, otherUpperInclusive, compare) &&
+		below(otherLower, otherLowerInclusive, upper, upperInclusive, compare) &&
+		// Exact と範囲の両方を持つ場合は、Exact 自体が範囲内である必要がある
+		r.selfConsistent(compare) && other.selfConsistent(compare)
+}
+
+func (r Range) lower() (string, bool) {
+	if r.Exact != "" {
+		return r.Exact, true
+	}
+	return r.Start, r.StartInclusive
+}
+
+func (r Range) upper() (string, bool) {
+	if r.Exact != "" {
+		return r.Exact, true
+	}
+	return r.End, r.EndInclusive
+}
+
+func (r Range) selfConsistent(compare Comparator) bool {
+	if r.Exact == "" || !r.IsBounded() {
+		return true
+	}
+	return Range{Start: r.Start, StartInclusive: r.StartInclusive, End: r.End, EndInclusive: r.EndInclusive}.
+		Overlaps(Range{Exact: r.Exact}, compare)
+}
+
+// below は下限 lower が上限 upper を超えていないかどうかを返します (どちらかが空なら true)
+func below(lower string, lowerInclusive bool, upper string, upperInclusive bool, compare Comparator) bool {
+	if lower == "" || upper == "" {
+		return true
+	}
+
+	c := compare(lower, upper)
+	return c < 0 || (c == 0 && lowerInclusive && upperInclusive)
+}
+
+func (r Range) String() string {
+	if r.IsAny() {
+		return "*"
+	}
+
+	var parts []string
+	if r.Exact != "" {
+		parts = append(parts, "= "+r.Exact)
+	}
+	if r.Start != "" {
+		if r.StartInclusive {
+			parts = append(parts, ">= "+r.Start)
+		} else {
+			parts = append(parts, "> "+r.Start)
+		}
+	}
+	if r.End != "" {
+		if r.EndInclusive {
+			parts = append(parts, "<= "+r.End)
+		} else {
+			parts = append(parts, "< "+r.End)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
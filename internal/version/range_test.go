@@ -0,0 +1,102 @@
+package version
+
+import "testing"
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Range
+	}{
+		{"", Range{}},
+		{"*", Range{}},
+		{"6.1.55", Range{Exact: "6.1.55"}},
+		{"= 6.1.55", Range{Exact: "6.1.55"}},
+		{">=6.1, <6.2", Range{Start: "6.1", StartInclusive: true, End: "6.2"}},
+		{"> 6.1 , <= 6.2", Range{Start: "6.1", End: "6.2", EndInclusive: true}},
+		{"<6.2", Range{End: "6.2"}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRange(tt.input)
+		if err != nil {
+			t.Errorf("ParseRange(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRange(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, input := range []string{">=", ">=6.1,", "6.1 6.2", ">= 6.1 < 6.2"} {
+		if got, err := ParseRange(input); err == nil {
+			t.Errorf("ParseRange(%q) = %+v, want error", input, got)
+		}
+	}
+}
+
+func TestRangeContains(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"*", "6.1.55", true},
+		{"6.1.55", "6.1.55", true},
+		{"6.1.55", "6.1.56", false},
+		{">=6.1, <6.2", "6.1", true},
+		{">=6.1, <6.2", "6.1.99", true},
+		{">=6.1, <6.2", "6.2", false},
+		{">=6.1, <6.2", "6.2-rc1", true},
+		{">6.1, <=6.2", "6.1", false},
+		{">6.1, <=6.2", "6.2", true},
+		{"<6.2", "5.15", true},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRange(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseRange(%q) error = %v", tt.constraint, err)
+		}
+		if got := r.Contains(tt.version, CompareDotted); got != tt.want {
+			t.Errorf("%q.Contains(%q) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestRangeOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"*", ">=6.1", true},
+		{">=6.1, <6.2", ">=6.1.50, <6.3", true},
+		{">=6.1, <6.2", ">=6.2", false},
+		{">=6.1, <=6.2", ">=6.2", true},
+		{"<6.1", ">6.1", false},
+		{"6.1.55", ">=6.1, <6.2", true},
+		{"6.1.55", "6.1.56", false},
+		// Exact と範囲の両方を持ち、Exact が範囲外なら "*" 以外とは重ならない
+		{"6.3, >=6.1, <6.2", "*", true},
+		{"6.3, >=6.1, <6.2", ">=6.0", false},
+	}
+
+	for _, tt := range tests {
+		a, err := ParseRange(tt.a)
+		if err != nil {
+			t.Fatalf("ParseRange(%q) error = %v", tt.a, err)
+		}
+		b, err := ParseRange(tt.b)
+		if err != nil {
+			t.Fatalf("ParseRange(%q) error = %v", tt.b, err)
+		}
+
+		if got := a.Overlaps(b, CompareDotted); got != tt.want {
+			t.Errorf("%q.Overlaps(%q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := b.Overlaps(a, CompareDotted); got != tt.want {
+			t.Errorf("%q.Overlaps(%q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
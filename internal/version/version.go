@@ -0,0 +1,166 @@
+// Package version は製品ごとの慣習に合わせたバージョン比較関数を提供します。
+// 各比較関数は a < b なら負、a == b なら 0、a > b なら正を返します。
+package version
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Comparator はバージョン比較関数です
+type Comparator func(a, b string) int
+
+// CompareDotted はLinuxカーネルなどのドット区切りのバージョンを比較します。
+// 足りない要素は0として扱い ("6.1" == "6.1.0")、"-rc1" のような接尾辞はプレリリースとして
+// 接尾辞のない同じバージョンより前と見なします ("6.7-rc1" < "6.7")。
+func CompareDotted(a, b string) int {
+	aRelease, aPre, _ := strings.Cut(a, "-")
+	bRelease, bPre, _ := strings.Cut(b, "-")
+
+	if c := compareSegments(strings.Split(aRelease, "."), strings.Split(bRelease, ".")); c != 0 {
+		return c
+	}
+
+	return comparePrerelease(aPre, bPre, compareNatural)
+}
+
+// CompareWindowsBuild は "10.0.19045.4291" のようなWindowsのビルド番号を比較します。
+// 数値以外の接尾辞は無視します。
+func CompareWindowsBuild(a, b string) int {
+	return compareSegments(buildSegments(a), buildSegments(b))
+}
+
+func buildSegments(v string) []string {
+	segments := strings.Split(v, ".")
+	for i, segment := range segments {
+		end := 0
+		for end < len(segment) && segment[end] >= '0' && segment[end] <= '9' {
+			end++
+		}
+		segments[i] = segment[:end]
+	}
+	return segments
+}
+
+// CompareSemver はセマンティックバージョニングの優先順位に従って比較します。
+// 先頭の "v" とビルドメタデータ ("+..." 以降) は無視します。
+func CompareSemver(a, b string) int {
+	aCore, aPre := splitSemver(a)
+	bCore, bPre := splitSemver(b)
+
+	if c := compareSegments(strings.Split(aCore, "."), strings.Split(bCore, ".")); c != 0 {
+		return c
+	}
+
+	return comparePrerelease(aPre, bPre, compareIdentifier)
+}
+
+func splitSemver(v string) (core, prerelease string) {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "+")
+	core, prerelease, _ = strings.Cut(v, "-")
+	return core, prerelease
+}
+
+// compareSegments は要素ごとに比較します。数値同士は数値として、それ以外は文字列として比較します。
+func compareSegments(a, b []string) int {
+	for i := range max(len(a), len(b)) {
+		aSegment, bSegment := "0", "0"
+		if i < len(a) && a[i] != "" {
+			aSegment = a[i]
+		}
+		if i < len(b) && b[i] != "" {
+			bSegment = b[i]
+		}
+
+		if c := compareIdentifier(aSegment, bSegment); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// comparePrerelease はプレリリース識別子を比較します。識別子がない方が新しいバージョンです。
+func comparePrerelease(a, b string, compareID func(a, b string) int) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	aIDs := strings.Split(a, ".")
+	bIDs := strings.Split(b, ".")
+	for i := range min(len(aIDs), len(bIDs)) {
+		if c := compareID(aIDs[i], bIDs[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(aIDs), len(bIDs))
+}
+
+// compareIdentifier は数値同士なら数値として比較し、数値は文字列より前とします
+func compareIdentifier(a, b string) int {
+	aNum, aErr := strconv.ParseUint(a, 10, 64)
+	bNum, bErr := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmp.Compare(aNum, bNum)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// compareNatural は数字の並びを数値として比較します ("rc2" < "rc10")
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		aChunk, aRest := cutChunk(a)
+		bChunk, bRest := cutChunk(b)
+
+		if c := compareIdentifier(aChunk, bChunk); c != 0 {
+			return c
+		}
+		a, b = aRest, bRest
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// cutChunk は先頭から数字のみ、または数字以外のみの連続した部分を切り出します
+func cutChunk(s string) (chunk, rest string) {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	end := 1
+	for end < len(s) && isDigit(s[end]) == isDigit(s[0]) {
+		end++
+	}
+	return s[:end], s[end:]
+}
+
+// Comparators は設定ファイルなどで指定するバージョン体系の名前と比較関数の対応です
+var Comparators = map[string]Comparator{
+	"dotted":        CompareDotted,
+	"windows-build": CompareWindowsBuild,
+	"semver":        CompareSemver,
+}
+
+// ComparatorFor はバージョン体系の名前に対応する比較関数を返します。空の場合は CompareDotted を返します。
+func ComparatorFor(scheme string) (Comparator, error) {
+	if scheme == "" {
+		return CompareDotted, nil
+	}
+
+	compare, ok := Comparators[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown version scheme %q", scheme)
+	}
+	return compare, nil
+}
@@ -0,0 +1,104 @@
+package version
+
+import "testing"
+
+// sign は比較結果を -1, 0, 1 に正規化します
+func sign(c int) int {
+	switch {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func testComparator(t *testing.T, name string, compare Comparator, tests []struct {
+	a, b string
+	want int
+}) {
+	t.Helper()
+
+	for _, tt := range tests {
+		if got := sign(compare(tt.a, tt.b)); got != tt.want {
+			t.Errorf("%s(%q, %q) = %d, want %d", name, tt.a, tt.b, got, tt.want)
+		}
+		// 引数を入れ替えた場合は符号が反転する
+		if got := sign(compare(tt.b, tt.a)); got != -tt.want {
+			t.Errorf("%s(%q, %q) = %d, want %d", name, tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestCompareDotted(t *testing.T) {
+	testComparator(t, "CompareDotted", CompareDotted, []struct {
+		a, b string
+		want int
+	}{
+		{"6.1", "6.1", 0},
+		{"6.1", "6.1.0", 0},
+		{"6.1.9", "6.1.10", -1},
+		{"6.10", "6.9", 1},
+		{"5.15.148", "6.1", -1},
+		{"6.7-rc1", "6.7", -1},
+		{"6.7-rc1", "6.6.30", 1},
+		{"6.7-rc2", "6.7-rc10", -1},
+		{"6.7-rc1", "6.7-rc1", 0},
+	})
+}
+
+func TestCompareWindowsBuild(t *testing.T) {
+	testComparator(t, "CompareWindowsBuild", CompareWindowsBuild, []struct {
+		a, b string
+		want int
+	}{
+		{"10.0.19045.4291", "10.0.19045.4291", 0},
+		{"10.0.19045.4291", "10.0.19045.4412", -1},
+		{"10.0.22631.3447", "10.0.19045.4412", 1},
+		{"10.0.19045", "10.0.19045.0", 0},
+		{"10.0.19045.4291", "10.0.19045.4291a", 0},
+		{"6.3.9600.17031", "10.0.10240.16384", -1},
+	})
+}
+
+func TestCompareSemver(t *testing.T) {
+	// semver.org 11. の優先順位の例を含む
+	testComparator(t, "CompareSemver", CompareSemver, []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "2.0.0", -1},
+		{"2.0.0", "2.1.0", -1},
+		{"2.1.0", "2.1.1", -1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta", "1.0.0-beta.2", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3+build.5", "1.2.3", 0},
+		{"1.10.0", "1.9.0", 1},
+	})
+}
+
+func TestComparatorFor(t *testing.T) {
+	for _, scheme := range []string{"", "dotted", "windows-build", "semver"} {
+		if compare, err := ComparatorFor(scheme); err != nil || compare == nil {
+			t.Errorf("ComparatorFor(%q) = %v, %v", scheme, compare, err)
+		}
+	}
+
+	// 空の場合は CompareDotted を使う
+	compare, _ := ComparatorFor("")
+	if compare("6.7-rc1", "6.7") >= 0 {
+		t.Errorf("ComparatorFor(\"\") does not behave as CompareDotted")
+	}
+
+	if _, err := ComparatorFor("calver"); err == nil {
+		t.Errorf("ComparatorFor(%q) error = nil, want error", "calver")
+	}
+}
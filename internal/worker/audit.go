@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nexryai/eleos/internal/db"
+)
+
+// ExecuteAudit は抑制ルールによって製品に該当しないと判断された脆弱性を、ルールと理由とともに w に出力します。
+// 製品は名前またはIDで指定します。
+func ExecuteAudit(ctx context.Context, nameOrID string, w io.Writer) error {
+	database, err := connectDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	document, err := findProduct(ctx, database, nameOrID)
+	if err != nil {
+		return err
+	}
+
+	vulns, err := db.ListNotAffectedVulnerabilities(ctx, database, document.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d suppressed vulnerabilities for %s\n", len(vulns), document.Name)
+
+	for _, v := range vulns {
+		for _, notAffected := range v.NotAffected {
+			if notAffected.ProductID != document.ID {
+				continue
+			}
+
+			fmt.Fprintf(w, "  %s (published %s)\n", v.CVE, v.PublishedAt.Format(time.DateOnly))
+			fmt.Fprintf(w, "    rule: %s\n", notAffected.Rule)
+			fmt.Fprintf(w, "    justification: %s\n", notAffected.Justification)
+			fmt.Fprintf(w, "    suppressed at: %s\n", notAffected.SuppressedAt.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nexryai/eleos/internal/db"
+	"github.com/nexryai/eleos/internal/nvd"
+)
+
+// backfillStateKey は開始日時ごとに進捗を区別するためのキーを返します。
+// 終了日時は省略時に実行時刻になるため、キーには含めません (進捗はどこまで取り込んだかで判断します)。
+func backfillStateKey(start time.Time) string {
+	return "backfill:" + start.UTC().Format(time.RFC3339)
+}
+
+// ExecuteBackfill は指定した期間に公開された過去の脆弱性を取り込みます。
+// 期間はNVDの上限に合わせて分割され、期間ごとに進捗を記録するため、
+// 中断された場合も同じ開始日時で再実行すれば (終了日時を省略した場合も) 続きから再開します。
+func ExecuteBackfill(ctx context.Context, client *nvd.Client, start, end time.Time) error {
+	if !start.Before(end) {
+		return fmt.Errorf("invalid backfill range: %s is not before %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	database, err := prepareJob(ctx)
+	if err != nil {
+		return err
+	}
+
+	stateKey := backfillStateKey(start)
+	state, err := db.GetIngestionState(ctx, database, stateKey)
+	if err != nil {
+		return err
+	}
+
+	resumeFrom := start
+	if state != nil {
+		if !state.HighWaterMark.Before(end) {
+			log.Printf("Backfill from %s to %s has already been completed.", start.Format(time.RFC3339), state.HighWaterMark.Format(time.RFC3339))
+			return nil
+		}
+
+		resumeFrom = state.HighWaterMark
+		log.Printf("Resuming backfill from %s", resumeFrom.Format(time.RFC3339))
+	}
+
+	windows := nvd.SplitDateRange(resumeFrom, end)
+	for i, window := range windows {
+		log.Printf("Backfilling window %d/%d...", i+1, len(windows))
+
+		if err := ingestWindow(ctx, database, client, ModePublished, window.Start, window.End, time.Time{}); err != nil {
+			return fmt.Errorf("backfill failed (window %s - %s): %w", window.Start.Format(time.RFC3339), window.End.Format(time.RFC3339), err)
+		}
+
+		if err := db.SetIngestionHighWaterMark(ctx, database, stateKey, window.End); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nexryai/eleos/internal/db"
+	"github.com/nexryai/eleos/internal/nvd"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ExecuteExplain はCVEを製品に対して評価し、設定・Node・CPEごとの評価結果を w に出力します。
+// 製品は名前またはIDで指定します (無効化されている製品も評価できます)。
+func ExecuteExplain(ctx context.Context, client *nvd.Client, cveID, nameOrID string, w io.Writer) error {
+	database, err := connectDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	document, err := findProduct(ctx, database, nameOrID)
+	if err != nil {
+		return err
+	}
+
+	definition, err := definitionFromDocument(*document)
+	if err != nil {
+		return err
+	}
+
+	item, err := client.FetchVulnerability(ctx, cveID)
+	if err != nil {
+		return err
+	}
+
+	evaluations := evaluateProduct(definition, productRuleLookup(definition), item.CVE.Configurations)
+	printEvaluations(w, item.CVE.ID, document.Name, evaluations)
+
+	return nil
+}
+
+// findProduct は名前またはIDから製品を探します
+func findProduct(ctx context.Context, database *mongo.Database, nameOrID string) (*db.Product, error) {
+	documents, err := db.ListProducts(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, document := range documents {
+		if document.ID.Hex() == nameOrID || strings.EqualFold(document.Name, nameOrID) {
+			return &document, nil
+		}
+	}
+
+	return nil, fmt.Errorf("product not found: %s", nameOrID)
+}
+
+func printEvaluations(w io.Writer, cveID, productName string, evaluations []configurationEvaluation) {
+	fmt.Fprintf(w, "%s against %s\n", cveID, productName)
+
+	if len(evaluations) == 0 {
+		fmt.Fprintln(w, "  No configurations (not analyzed yet).")
+	}
+
+	matched := false
+	for cfgIndex, cfg := range evaluations {
+		matched = matched || cfg.matched()
+
+		fmt.Fprintf(w, "  Configuration #%d (%s) => %s%s\n",
+			cfgIndex, operatorName(cfg.configuration.Operator), cfg.result, verdict(cfg.matched()))
+
+		for nodeIndex, node := range cfg.nodes {
+			negate := ""
+			if node.node.Negate {
+				negate = ", negate"
+			}
+			fmt.Fprintf(w, "    Node #%d (%s%s) => %s\n", nodeIndex, operatorName(node.node.Operator), negate, node.result)
+
+			for _, cpe := range node.cpes {
+				printCPEEvaluation(w, cpe)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Result:%s\n", verdict(matched))
+}
+
+func printCPEEvaluation(w io.Writer, evaluation cpeEvaluation) {
+	role := "platform"
+	if evaluation.match.Vulnerable {
+		role = "vulnerable"
+	}
+
+	fmt.Fprintf(w, "      [%-7s] %s %s\n", evaluation.result, role, evaluation.match.Criteria)
+	fmt.Fprintf(w, "                matchCriteriaId: %s\n", evaluation.match.MatchCriteriaID)
+	fmt.Fprintf(w, "                versions: %s\n", evaluation.match.VersionRange())
+
+	switch {
+	case evaluation.rule == "":
+		fmt.Fprintln(w, "                criteria does not match any product rule")
+	case !evaluation.versionMatched:
+		fmt.Fprintf(w, "                rule: %s (no deployed version in range)\n", evaluation.rule)
+	default:
+		fmt.Fprintf(w, "                rule: %s\n", evaluation.rule)
+	}
+}
+
+func operatorName(operator string) string {
+	if operator == "" {
+		return "OR"
+	}
+	return operator
+}
+
+func verdict(matched bool) string {
+	if matched {
+		return " MATCHED"
+	}
+	return " not matched"
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nexryai/eleos/internal/db"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ExecuteHistory はCVEの変更履歴を古い順に w に出力します
+func ExecuteHistory(ctx context.Context, cveID string, w io.Writer) error {
+	database, err := connectDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := db.ListVulnerabilityRevisions(ctx, database, cveID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d revisions for %s\n", len(revisions), cveID)
+
+	for _, revision := range revisions {
+		fmt.Fprintf(w, "  %s (run %s", revision.CreatedAt.Format(time.RFC3339), revision.RunID)
+		if !revision.LastModified.IsZero() {
+			fmt.Fprintf(w, ", NVD lastModified %s", revision.LastModified.Format(time.RFC3339))
+		}
+		fmt.Fprintln(w, ")")
+
+		for _, change := range revision.Changes {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", change.Field, formatValue(change.Old), formatValue(change.New))
+		}
+	}
+
+	return nil
+}
+
+// formatValue は変更前後のBSONの値を表示用の文字列に変換します
+func formatValue(value any) string {
+	if value == nil {
+		return "(none)"
+	}
+
+	t, data, err := bson.MarshalValue(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return bson.RawValue{Type: t, Value: data}.String()
+}
@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/nexryai/eleos/internal/nvd"
+)
+
+// ExecuteImport はローカルディレクトリに置かれたNVDのデータフィードから脆弱性を取り込みます。
+// APIにアクセスできない環境での初期投入や、全期間の一括取り込みに使用します。
+func ExecuteImport(ctx context.Context, dir string) error {
+	database, err := prepareJob(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Importing vulnerabilities from feeds in %s...", dir)
+	result, err := ingestVulnerabilities(ctx, database, "feed", nvd.ReadFeeds(ctx, dir), nil)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	log.Printf("Successfully imported %d total vulnerabilities! (matched: %d, not scored yet: %d)", result.Fetched, result.Matched, result.Unscored)
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"time"
+
+	"github.com/nexryai/eleos/internal/db"
+	"github.com/nexryai/eleos/internal/nvd"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	// 1トランザクションで書き込む脆弱性の最大数
+	writeBatchSize = 500
+	// 進捗がまだ記録されていない場合に遡る期間
+	defaultInitialWindow = 30 * time.Minute
+)
+
+// IngestMode はNVDから脆弱性を取得する際にどの日付で期間を指定するかを表します
+type IngestMode string
+
+const (
+	// ModePublished は期間内に新しく公開された脆弱性を取り込みます
+	ModePublished IngestMode = "published"
+	// ModeModified は期間内に更新された既存の脆弱性を取り込みます
+	// (NVDの解析でCPE設定やCVSSスコアが後から追加されたものなど)
+	ModeModified IngestMode = "modified"
+)
+
+// ingestResult は1回の取り込みの集計結果です
+type ingestResult struct {
+	Fetched int
+	Matched int
+	// Skipped は別のモードで処理されるため読み飛ばした件数です
+	Skipped int
+	// Unscored は製品にマッチしたもののスコアがまだないため保存しなかった件数です
+	Unscored int
+}
+
+func (m IngestMode) fetch(ctx context.Context, client *nvd.Client, start, end time.Time) iter.Seq2[nvd.VulnerabilityItem, error] {
+	if m == ModeModified {
+		return client.FetchModifiedVulnerabilities(ctx, start, end)
+	}
+	return client.FetchVulnerabilities(ctx, start, end)
+}
+
+// accepts は脆弱性をこのモードで処理すべきかどうかを判定します。
+// publishedFrom は今回の実行で ModePublished が取り込んだ期間の始まりで、それ以降に公開されたCVEは
+// 最新の内容で ModePublished 側で処理されるため、ModeModified では扱いません (ゼロ値の場合は全て処理します)。
+func (m IngestMode) accepts(item nvd.VulnerabilityItem, publishedFrom time.Time) bool {
+	if m == ModeModified && !publishedFrom.IsZero() {
+		return item.CVE.Published.Time.Before(publishedFrom)
+	}
+	return true
+}
+
+func (m IngestMode) stateKey() string {
+	return "cursor:" + string(m)
+}
+
+// ingestSinceHighWaterMark は前回の取り込み位置から現在までの脆弱性を取り込みます。
+// 取りこぼしを防ぐため、前回の位置から overlap だけ遡って取得します。
+// 期間がNVDの上限を超える場合は複数の期間に分割し、期間ごとに進捗を記録します。
+// publishedFrom は accepts に渡す、今回の実行で ModePublished が取り込んだ期間の始まりです。
+func ingestSinceHighWaterMark(ctx context.Context, database *mongo.Database, client *nvd.Client, mode IngestMode, overlap time.Duration, publishedFrom time.Time) error {
+	end := time.Now()
+	start := end.Add(-defaultInitialWindow)
+
+	state, err := db.GetIngestionState(ctx, database, mode.stateKey())
+	if err != nil {
+		return err
+	}
+
+	if state != nil {
+		start = state.HighWaterMark.Add(-overlap)
+	} else {
+		log.Printf("No high-water mark found for %s vulnerabilities, starting from %s", mode, start.Format(time.RFC3339))
+	}
+
+	windows := nvd.SplitDateRange(start, end)
+	if len(windows) > 1 {
+		log.Printf("Date range exceeds the NVD limit, splitting into %d windows", len(windows))
+	}
+
+	for _, window := range windows {
+		if err := ingestWindow(ctx, database, client, mode, window.Start, window.End, publishedFrom); err != nil {
+			return err
+		}
+
+		if err := db.SetIngestionHighWaterMark(ctx, database, mode.stateKey(), window.End); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publishedCoverageStart は今回の実行で ModePublished が取り込む期間の始まりを、取り込み前の進捗から返します。
+// 進捗がまだ記録されていない場合はゼロ値を返します。
+func publishedCoverageStart(ctx context.Context, database *mongo.Database, overlap time.Duration) (time.Time, error) {
+	state, err := db.GetIngestionState(ctx, database, ModePublished.stateKey())
+	if err != nil || state == nil {
+		return time.Time{}, err
+	}
+	return state.HighWaterMark.Add(-overlap), nil
+}
+
+func ingestWindow(ctx context.Context, database *mongo.Database, client *nvd.Client, mode IngestMode, start, end, publishedFrom time.Time) error {
+	log.Printf("Fetching %s vulnerabilities between %s and %s\n",
+		mode,
+		start.Format(time.RFC3339),
+		end.Format(time.RFC3339),
+	)
+
+	accept := func(item nvd.VulnerabilityItem) bool {
+		return mode.accepts(item, publishedFrom)
+	}
+
+	result, err := ingestVulnerabilities(ctx, database, string(mode), mode.fetch(ctx, client, start, end), accept)
+	if err != nil {
+		log.Printf("Error fetching vulnerabilities: %v\n", err)
+		return err
+	}
+
+	if result.Fetched == 0 {
+		log.Printf("No %s vulnerabilities found in the specified date range.", mode)
+		return nil
+	}
+
+	log.Printf("Successfully fetched %d total %s vulnerabilities! (matched: %d, not scored yet: %d, skipped: %d)",
+		result.Fetched,
+		mode,
+		result.Matched,
+		result.Unscored,
+		result.Skipped,
+	)
+
+	return nil
+}
+
+// ingestVulnerabilities は脆弱性を1件ずつ受け取り、マッチしたものを一定件数ごとにDBへ書き込みます。
+// accept が false を返した脆弱性は処理せずに読み飛ばします (nil の場合は全て処理します)。
+func ingestVulnerabilities(ctx context.Context, database *mongo.Database, source string, items iter.Seq2[nvd.VulnerabilityItem, error], accept func(nvd.VulnerabilityItem) bool) (*ingestResult, error) {
+	fmt.Printf("\n--- Displaying %s results ---\n", source)
+
+	result := &ingestResult{}
+	batch := make([]db.Vulnerability, 0, writeBatchSize)
+	// 設定があるのにどの製品にもマッチしなかったCVE (暫定的なマッチがあれば取り消す)
+	unmatched := make([]string, 0, writeBatchSize)
+
+	flush := func() error {
+		if len(unmatched) > 0 {
+			discarded, err := db.DiscardTentativeVulnerabilities(ctx, database, unmatched)
+			if err != nil {
+				return err
+			}
+			if discarded > 0 {
+				log.Printf("Discarded %d tentative vulnerabilities that did not match after analysis.", discarded)
+			}
+
+			unmatched = unmatched[:0]
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		log.Printf("Writing %d vulnerabilities to DB...", len(batch))
+		if err := db.UpsertVulnerabilityBatch(ctx, database, &batch, runID); err != nil {
+			return fmt.Errorf("a database transaction failed. aborting.: %w", err)
+		}
+
+		batch = batch[:0]
+		return nil
+	}
+
+	for item, err := range items {
+		if err != nil {
+			return result, fmt.Errorf("error fetching vulnerabilities: %w", err)
+		}
+		result.Fetched++
+
+		if accept != nil && !accept(item) {
+			result.Skipped++
+			continue
+		}
+
+		vuln, err := processVulnerability(item)
+		if errors.Is(err, errNotScored) {
+			// 製品は確定したがスコアがまだないため、暫定的なマッチは取り消さずにスコアの付与を待つ
+			result.Unscored++
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("error processing vulnerabilities: %w", err)
+		}
+		if vuln == nil {
+			if len(item.CVE.Configurations) > 0 {
+				unmatched = append(unmatched, item.CVE.ID)
+				// 登録済みの場合は、マッチしなくなった製品を取り除く
+				batch = append(batch, *newVulnerability(item))
+			}
+		} else {
+			result.Matched++
+			batch = append(batch, *vuln)
+		}
+
+		if len(batch) >= writeBatchSize || len(unmatched) >= writeBatchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, flush()
+}
@@ -5,53 +5,125 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/nexryai/eleos/internal/db"
+	"github.com/nexryai/eleos/internal/nvd"
+	"github.com/nexryai/eleos/internal/osv"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 var (
 	dbConnectString = os.Getenv("DB_CONNECT_STRING")
+	// runID は prepareJob で生成される実行ごとのIDで、脆弱性の変更履歴に記録されます
+	runID string
 )
 
 func getEnv(key, fallback string) string {
-    if value, ok := os.LookupEnv(key); ok {
-        return value
-    }
-    return fallback
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration in %s: %w", key, err)
+	}
+	return d, nil
 }
 
-func ExecuteJob() error {
-	log.Print("Fetching vulnerabilities...")
-	nvdVulnerabilities, err := fetchNewVulnerabilities()
+// connectDatabase はデータベースに接続するだけで、インデックスの作成や移行などの書き込みは行いません。
+// explain や audit などの読み取り専用のコマンドで使います。
+func connectDatabase(ctx context.Context) (*mongo.Database, error) {
+	database, err := db.NewDBClient(ctx, dbConnectString, getEnv("DB_NAME", "eleos-dev"))
 	if err != nil {
-		return fmt.Errorf("error executing job: %w", err)
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return database, nil
+}
+
+// prepareJob は設定を検証してデータベースに接続し、監視対象の製品を読み込みます
+func prepareJob(ctx context.Context) (*mongo.Database, error) {
+	database, err := openDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadProducts(ctx, database); err != nil {
+		return nil, err
+	}
+
+	if err := reconcileSuppressions(ctx, database); err != nil {
+		return nil, err
 	}
 
-	if nvdVulnerabilities == nil {
-		// No vulnerabilities found, nothing to process
-		return nil
+	return database, nil
+}
+
+// openDatabase は設定を検証してデータベースに接続し、インデックスの作成と移行を行います
+func openDatabase(ctx context.Context) (*mongo.Database, error) {
+	if err := scorePolicy.validate(); err != nil {
+		return nil, err
 	}
 
-	log.Print("Parsing vulnerabilities...")
-	vulnerabilities, err := processVulnerabilities(nvdVulnerabilities)
+	runID = bson.NewObjectID().Hex()
+	log.Printf("Starting run %s", runID)
+
+	database, err := connectDatabase(ctx)
 	if err != nil {
-		return fmt.Errorf("error processing vulnerabilities: %w", err)
+		return nil, err
+	}
+
+	if err := db.CreateDatabaseIndex(ctx, database); err != nil {
+		log.Fatalf("Failed to create database index: %v", err)
+	}
+
+	if err := db.MigrateDatabase(ctx, database); err != nil {
+		return nil, fmt.Errorf("database migration failed: %w", err)
 	}
 
-	dbContext := context.Background()
-	database, err := db.NewDBClient(dbContext, dbConnectString, getEnv("DB_NAME", "eleos-dev"))
+	return database, nil
+}
+
+// ExecuteJob はNVDから新しく公開・更新された脆弱性を取り込み、
+// 製品がパッケージを宣言している場合はOSVからパッケージの脆弱性も取り込みます
+func ExecuteJob(ctx context.Context, client *nvd.Client, osvClient *osv.Client) error {
+	// 前回の取り込み位置からどれだけ遡って再取得するか
+	overlap, err := getEnvDuration("INGEST_OVERLAP", 10*time.Minute)
 	if err != nil {
-		return fmt.Errorf("database error: %w", err)
+		return err
 	}
 
-	if err := db.CreateDatabaseIndex(dbContext, database); err != nil {
-        log.Fatalf("Failed to create database index: %v", err)
-    }
+	database, err := prepareJob(ctx)
+	if err != nil {
+		return err
+	}
 
-	log.Print("Writing to DB...")
-	err = db.CreateVulnerabilityBatch(dbContext, database, vulnerabilities)
+	// 2つのモードの進捗は独立して進むため、ModeModified で読み飛ばす範囲は
+	// ModePublished の期間から決める (ModeModified の期間の始まりとは限らない)
+	publishedFrom, err := publishedCoverageStart(ctx, database, overlap)
 	if err != nil {
-		return fmt.Errorf("a database transaction failed. aborting.: %w", err)
+		return err
+	}
+
+	// 新規公開分を先に処理し、その後で更新された既存の脆弱性を処理する
+	for _, mode := range []IngestMode{ModePublished, ModeModified} {
+		log.Printf("Fetching %s vulnerabilities...", mode)
+		if err := ingestSinceHighWaterMark(ctx, database, client, mode, overlap, publishedFrom); err != nil {
+			return fmt.Errorf("error executing job (%s): %w", mode, err)
+		}
+	}
+
+	if err := ingestPackages(ctx, database, osvClient, client); err != nil {
+		return fmt.Errorf("error executing job (packages): %w", err)
 	}
 
 	return nil
@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nexryai/eleos/internal/cpe"
+	"github.com/nexryai/eleos/internal/nvd"
+	"github.com/nexryai/eleos/internal/purl"
+)
+
+// indexKey は索引のキー (part, vendor, product の小文字のWFN) です
+type indexKey struct {
+	part, vendor, product string
+}
+
+// matcher は製品のCPEパターンを part / vendor / product で索引付けし、
+// CVEのcriteriaに該当しうる製品だけを定数時間で絞り込みます。
+// 絞り込んだ製品は Product.MatchName で改めて照合するため、結果は全ての製品を照合した場合と変わりません。
+type matcher struct {
+	products []Product
+	// ids は製品のIDごとの位置です
+	ids map[string]int
+	// exact は part, vendor, product が全てワイルドカードを含まない文字列のパターンです
+	exact map[indexKey][]int
+	// byVendor は product にワイルドカードを含むか ANY のパターンです (product は空)
+	byVendor map[indexKey][]int
+	// fallback は part または vendor が索引に使えないパターンで、常に照合します
+	fallback []int
+	// packages はパッケージURL (バージョンなし) ごとの製品です
+	packages map[string]*packageIndex
+}
+
+type packageIndex struct {
+	url      purl.PackageURL
+	products []int
+}
+
+// productMatcher は loadProducts で products から生成される索引です
+var productMatcher = newMatcher(nil)
+
+func newMatcher(products []Product) *matcher {
+	m := &matcher{
+		products: products,
+		ids:      make(map[string]int, len(products)),
+		exact:    make(map[indexKey][]int),
+		byVendor: make(map[indexKey][]int),
+		packages: make(map[string]*packageIndex),
+	}
+
+	for i, product := range products {
+		m.ids[product.UUID()] = i
+		for _, pattern := range product.Patterns() {
+			m.add(i, pattern)
+		}
+		for _, pkg := range product.PackageURLs() {
+			key := pkg.Package().String()
+			if m.packages[key] == nil {
+				m.packages[key] = &packageIndex{url: pkg.Package()}
+			}
+			m.packages[key].products = appendUnique(m.packages[key].products, i)
+		}
+	}
+
+	return m
+}
+
+func (m *matcher) add(productIndex int, pattern cpe.Name) {
+	part, partOK := indexValue(pattern.Get(cpe.Part))
+	vendor, vendorOK := indexValue(pattern.Get(cpe.Vendor))
+	product, productOK := indexValue(pattern.Get(cpe.Product))
+
+	switch {
+	case !partOK || !vendorOK:
+		m.fallback = appendUnique(m.fallback, productIndex)
+	case !productOK:
+		key := indexKey{part: part, vendor: vendor}
+		m.byVendor[key] = appendUnique(m.byVendor[key], productIndex)
+	default:
+		key := indexKey{part: part, vendor: vendor, product: product}
+		m.exact[key] = appendUnique(m.exact[key], productIndex)
+	}
+}
+
+// indexValue は索引に使える値 (ワイルドカードを含まない文字列) を小文字で返します
+func indexValue(v cpe.Value) (string, bool) {
+	if v.Kind != cpe.Literal || v.HasWildcards() {
+		return "", false
+	}
+	return strings.ToLower(v.WFN()), true
+}
+
+// appendUnique は同じ製品の複数のパターンが同じキーに入る場合に重複を避けます
+func appendUnique(indices []int, i int) []int {
+	if len(indices) > 0 && indices[len(indices)-1] == i {
+		return indices
+	}
+	return append(indices, i)
+}
+
+// candidates は criteria に該当しうる製品の位置を返します
+func (m *matcher) candidates(name cpe.Name) []int {
+	part, partOK := indexValue(name.Get(cpe.Part))
+	vendor, vendorOK := indexValue(name.Get(cpe.Vendor))
+	product, productOK := indexValue(name.Get(cpe.Product))
+
+	if !partOK || !vendorOK || !productOK {
+		// criteria 側にワイルドカードがある場合は索引を使えないため、全ての製品を候補にする
+		all := make([]int, len(m.products))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	var indices []int
+	indices = append(indices, m.exact[indexKey{part: part, vendor: vendor, product: product}]...)
+	indices = append(indices, m.byVendor[indexKey{part: part, vendor: vendor}]...)
+	indices = append(indices, m.fallback...)
+	return indices
+}
+
+// product はIDから製品を返します
+func (m *matcher) product(id string) (Product, bool) {
+	i, ok := m.ids[id]
+	if !ok {
+		return nil, false
+	}
+	return m.products[i], true
+}
+
+// packageURLs は製品が宣言した全てのパッケージのURLを重複なしで返します
+func (m *matcher) packageURLs() []purl.PackageURL {
+	urls := make([]purl.PackageURL, 0, len(m.packages))
+	for _, key := range slices.Sorted(maps.Keys(m.packages)) {
+		urls = append(urls, m.packages[key].url)
+	}
+	return urls
+}
+
+// packageCandidates はパッケージを宣言している製品の位置を返します
+func (m *matcher) packageCandidates(pkg purl.PackageURL) []int {
+	if index, ok := m.packages[pkg.Package().String()]; ok {
+		return index.products
+	}
+	return nil
+}
+
+// productRules は製品ごとに、criteria とそれに該当した製品のCPEパターンを保持します
+type productRules map[int]map[string]string
+
+// match はCVEの全てのcriteriaを索引で引き、該当した製品ごとのCPEパターンを返します。
+// 同じcriteriaが複数の設定に現れても、照合は1回だけ行います。
+func (m *matcher) match(configurations []nvd.Configuration) productRules {
+	rules := make(productRules)
+	seen := make(map[string]struct{})
+
+	for _, cfg := range configurations {
+		for _, node := range cfg.Nodes {
+			for _, match := range node.CPEMatch {
+				if _, ok := seen[match.Criteria]; ok {
+					continue
+				}
+				seen[match.Criteria] = struct{}{}
+
+				name, err := cpe.Parse(match.Criteria)
+				if err != nil {
+					continue
+				}
+
+				for _, i := range m.candidates(name) {
+					rule, ok := m.products[i].MatchName(name)
+					if !ok {
+						continue
+					}
+
+					if rules[i] == nil {
+						rules[i] = make(map[string]string)
+					}
+					rules[i][match.Criteria] = rule
+				}
+			}
+		}
+	}
+
+	return rules
+}
+
+// ruleLookup は criteria に該当した製品のCPEパターンを返します
+type ruleLookup func(criteria string) (string, bool)
+
+// lookup は製品の照合結果を ruleLookup として返します
+func (r productRules) lookup(productIndex int) ruleLookup {
+	criteria := r[productIndex]
+	return func(c string) (string, bool) {
+		rule, ok := criteria[c]
+		return rule, ok
+	}
+}
+
+// productRuleLookup は索引を使わずに1つの製品だけを照合する ruleLookup を返します
+func productRuleLookup(product Product) ruleLookup {
+	return func(criteria string) (string, bool) {
+		name, err := cpe.Parse(criteria)
+		if err != nil {
+			return "", false
+		}
+		return product.MatchName(name)
+	}
+}
@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/nexryai/eleos/internal/cpe"
+	"github.com/nexryai/eleos/internal/nvd"
+	"github.com/nexryai/eleos/internal/product"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	syntheticProducts = 3000
+	syntheticVendors  = 400
+	syntheticCVEs     = 200
+	criteriaPerCVE    = 5
+)
+
+// syntheticDefinitions は索引の3種類 (exact, byVendor, fallback) のパターンを含む製品定義を生成します
+func syntheticDefinitions(tb testing.TB, rng *rand.Rand) []Product {
+	tb.Helper()
+
+	var config struct {
+		Products []map[string]any `json:"products"`
+	}
+	for i := range syntheticProducts {
+		vendor := fmt.Sprintf("vendor%d", rng.IntN(syntheticVendors))
+		var pattern string
+		switch {
+		case i%100 == 0:
+			// part が ANY のパターンは索引に使えないため、常に照合される
+			pattern = fmt.Sprintf("cpe:2.3:*:%s:product%d:*:*:*:*:*:*:*:*", vendor, i)
+		case i%10 == 1:
+			pattern = fmt.Sprintf("cpe:2.3:a:%s:*:*:*:*:*:*:*:*:*", vendor)
+		case i%10 == 2:
+			pattern = fmt.Sprintf("cpe:2.3:a:%s:product%d_*:*:*:*:*:*:*:*:*", vendor, i)
+		default:
+			pattern = fmt.Sprintf("cpe:2.3:a:%s:product%d:*:*:*:*:*:*:*:*", vendor, i)
+		}
+
+		config.Products = append(config.Products, map[string]any{
+			"name":     fmt.Sprintf("Product %d", i),
+			"id":       bson.NewObjectID().Hex(),
+			"cpes":     []string{pattern},
+			"exclude":  []string{fmt.Sprintf("cpe:2.3:a:%s:product%d:0.*:*:*:*:*:*:*:*", vendor, i)},
+			"versions": []string{fmt.Sprintf(">= %d.0, < %d.0", i%7, i%7+2)},
+		})
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	definitions, err := product.Load(bytes.NewReader(data))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	products := make([]Product, 0, len(definitions))
+	for _, definition := range definitions {
+		products = append(products, definition)
+	}
+	return products
+}
+
+// syntheticConfigurations は実在する製品と存在しない製品のcriteriaを混ぜたCVEの設定を生成します
+func syntheticConfigurations(rng *rand.Rand) [][]nvd.Configuration {
+	cves := make([][]nvd.Configuration, 0, syntheticCVEs)
+	for range syntheticCVEs {
+		var node nvd.Node
+		for range criteriaPerCVE {
+			criteria := fmt.Sprintf("cpe:2.3:a:vendor%d:product%d:%d.%d:*:*:*:*:*:*:*",
+				rng.IntN(syntheticVendors), rng.IntN(syntheticProducts*2), rng.IntN(10), rng.IntN(10))
+			if rng.IntN(4) == 0 {
+				// バージョンが ANY の範囲指定
+				criteria = fmt.Sprintf("cpe:2.3:a:vendor%d:product%d:*:*:*:*:*:*:*:*", rng.IntN(syntheticVendors), rng.IntN(syntheticProducts*2))
+			}
+			node.CPEMatch = append(node.CPEMatch, nvd.CPEMatch{Vulnerable: true, Criteria: criteria})
+		}
+		cves = append(cves, []nvd.Configuration{{Nodes: []nvd.Node{node}}})
+	}
+	return cves
+}
+
+// linearMatch は索引を使わずに全ての製品を照合します (索引を導入する前の方法)
+func linearMatch(products []Product, configurations []nvd.Configuration) productRules {
+	rules := make(productRules)
+	for _, cfg := range configurations {
+		for _, node := range cfg.Nodes {
+			for _, match := range node.CPEMatch {
+				name, err := cpe.Parse(match.Criteria)
+				if err != nil {
+					continue
+				}
+
+				for i, product := range products {
+					rule, ok := product.MatchName(name)
+					if !ok {
+						continue
+					}
+					if rules[i] == nil {
+						rules[i] = make(map[string]string)
+					}
+					rules[i][match.Criteria] = rule
+				}
+			}
+		}
+	}
+	return rules
+}
+
+func TestMatcherMatchesLinearScan(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	products := syntheticDefinitions(t, rng)
+	m := newMatcher(products)
+
+	matched := 0
+	for _, configurations := range syntheticConfigurations(rng) {
+		want := linearMatch(products, configurations)
+		got := m.match(configurations)
+
+		if !maps.EqualFunc(got, want, maps.Equal) {
+			t.Fatalf("index matched %v, linear scan matched %v", slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+		}
+		matched += len(got)
+	}
+
+	if matched == 0 {
+		t.Fatal("synthetic CVEs did not match any product")
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	products := syntheticDefinitions(b, rng)
+	cves := syntheticConfigurations(rng)
+
+	b.Run("index", func(b *testing.B) {
+		m := newMatcher(products)
+		b.ResetTimer()
+		for i := range b.N {
+			m.match(cves[i%len(cves)])
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		for i := range b.N {
+			linearMatch(products, cves[i%len(cves)])
+		}
+	})
+}
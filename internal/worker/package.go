@@ -0,0 +1,261 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/nexryai/eleos/internal/db"
+	"github.com/nexryai/eleos/internal/nvd"
+	"github.com/nexryai/eleos/internal/osv"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// processPackageVulnerability はOSVの脆弱性を製品が宣言したパッケージと照合し、DBに保存する形式に変換します。
+// どの製品にもマッチしない場合や取り下げられた場合は nil を返します。
+func processPackageVulnerability(vuln osv.Vulnerability) (*db.Vulnerability, error) {
+	if vuln.Withdrawn != nil {
+		return nil, nil
+	}
+
+	var productIDs []bson.ObjectID
+	var matches []db.PackageMatch
+
+	for _, affected := range vuln.Affected {
+		pkg, err := affected.Package.PackageURL()
+		if err != nil {
+			// 対応していないエコシステムは製品が宣言することもないため読み飛ばす
+			continue
+		}
+
+		ranges := affected.VersionRanges()
+		for _, i := range productMatcher.packageCandidates(pkg) {
+			product := productMatcher.products[i]
+
+			rule, ok := product.MatchPackage(pkg, ranges)
+			if !ok {
+				continue
+			}
+
+			productObjectID, err := bson.ObjectIDFromHex(product.UUID())
+			if err != nil {
+				return nil, fmt.Errorf("invalid object id %q: %w", product.UUID(), err)
+			}
+
+			if !slices.Contains(productIDs, productObjectID) {
+				productIDs = append(productIDs, productObjectID)
+			}
+
+			rangeStrings := make([]string, 0, len(ranges))
+			for _, r := range ranges {
+				rangeStrings = append(rangeStrings, r.String())
+			}
+			matches = append(matches, db.PackageMatch{
+				ProductID: productObjectID,
+				Rule:      rule,
+				Source:    vuln.ID,
+				Ranges:    rangeStrings,
+			})
+		}
+	}
+
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	// 脆弱性はCVE IDで管理するため、CVE IDがないパッケージの脆弱性 (GHSAのみなど) は登録しない
+	id := vuln.CVE()
+	if id == "" {
+		log.Printf("Skipping %s because it has no CVE alias.", vuln.ID)
+		return nil, nil
+	}
+
+	fmt.Printf("\nCVE ID: %s (%s)\n", id, vuln.ID)
+	for _, productID := range productIDs {
+		fmt.Printf("  Matched Product UUID: %s\n", productID.Hex())
+	}
+
+	description := vuln.Details
+	if description == "" {
+		description = vuln.Summary
+	}
+
+	v := &db.Vulnerability{
+		CVE:            id,
+		PublishedAt:    vuln.Published,
+		Description:    description,
+		ProductIDs:     productIDs,
+		PackageMatches: matches,
+	}
+	if ghsa := vuln.GHSA(); ghsa != "" {
+		v.GHSA = &ghsa
+	}
+
+	applySuppressions(v, time.Now())
+
+	// OSVの severity はベクターのみのため、スコアなどはNVDから取得して withNVDFields で設定する
+	return v, nil
+}
+
+// withNVDFields はパッケージの照合結果に、NVDから取得した説明・スコア・CWEなどの項目を加えた脆弱性を返します。
+// NVDにスコアがまだない場合は errNotScored を返します。
+func withNVDFields(item nvd.VulnerabilityItem, pkg *db.Vulnerability) (*db.Vulnerability, error) {
+	v := newVulnerability(item)
+	if !v.IsScored() {
+		return nil, errNotScored
+	}
+
+	v.GHSA = pkg.GHSA
+	v.ProductIDs = pkg.ProductIDs
+	v.PackageMatches = pkg.PackageMatches
+	v.NotAffectedProductIDs = pkg.NotAffectedProductIDs
+	v.NotAffected = pkg.NotAffected
+	return v, nil
+}
+
+// ingestPackages は製品が宣言したパッケージの脆弱性をOSVから取り込みます。
+// スコアのある脆弱性として登録されていないCVEは、NVDから説明やスコアを取得して登録します。
+func ingestPackages(ctx context.Context, database *mongo.Database, client *osv.Client, nvdClient *nvd.Client) error {
+	packages := productMatcher.packageURLs()
+	if len(packages) == 0 {
+		return nil
+	}
+
+	result := &ingestResult{}
+	batch := make([]db.Vulnerability, 0, writeBatchSize)
+	// 同じ脆弱性は複数のパッケージ・複数のOSVレコード (GHSAとGOなど) から得られることがある
+	seen := make(map[string]struct{})
+	batched := make(map[string]int)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		cves := make([]string, 0, len(batch))
+		for _, v := range batch {
+			cves = append(cves, v.CVE)
+		}
+		scored, err := db.ListScoredCVEs(ctx, database, cves)
+		if err != nil {
+			return fmt.Errorf("failed to find existing vulnerabilities: %w", err)
+		}
+
+		// 登録済みのCVEには製品を追加するだけにし、それ以外はNVDの項目を加えて登録する
+		var additions, enriched []db.Vulnerability
+		for i := range batch {
+			if _, ok := scored[batch[i].CVE]; ok {
+				additions = append(additions, batch[i])
+				continue
+			}
+
+			item, err := nvdClient.FetchVulnerability(ctx, batch[i].CVE)
+			if errors.Is(err, nvd.ErrVulnerabilityNotFound) {
+				log.Printf("Skipping %s because it is not found in NVD yet.", batch[i].CVE)
+				result.Unscored++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("error fetching vulnerability from NVD: %w", err)
+			}
+
+			v, err := withNVDFields(*item, &batch[i])
+			if errors.Is(err, errNotScored) {
+				// 次回の実行で再び照合される
+				log.Printf("Skipping %s because it has not been scored yet.", batch[i].CVE)
+				result.Unscored++
+				continue
+			}
+			enriched = append(enriched, *v)
+		}
+
+		log.Printf("Writing %d vulnerabilities to DB...", len(additions)+len(enriched))
+		if err := db.CreateVulnerabilityBatch(ctx, database, &additions, runID); err != nil {
+			return fmt.Errorf("a database transaction failed. aborting.: %w", err)
+		}
+		if err := db.UpsertVulnerabilityBatch(ctx, database, &enriched, runID); err != nil {
+			return fmt.Errorf("a database transaction failed. aborting.: %w", err)
+		}
+
+		batch = batch[:0]
+		clear(batched)
+		return nil
+	}
+
+	for _, pkg := range packages {
+		log.Printf("Fetching OSV vulnerabilities for %s", pkg)
+
+		for vuln, err := range client.QueryPackage(ctx, pkg) {
+			if err != nil {
+				return fmt.Errorf("error fetching package vulnerabilities: %w", err)
+			}
+			result.Fetched++
+
+			if _, ok := seen[vuln.ID]; ok {
+				result.Skipped++
+				continue
+			}
+			seen[vuln.ID] = struct{}{}
+
+			v, err := processPackageVulnerability(vuln)
+			if err != nil {
+				return fmt.Errorf("error processing package vulnerabilities: %w", err)
+			}
+			if v == nil {
+				continue
+			}
+			result.Matched++
+
+			// 同じCVEのレコードがバッチ内にあれば、製品とマッチの理由をまとめる
+			if i, ok := batched[v.CVE]; ok {
+				mergePackageMatches(&batch[i], v)
+				continue
+			}
+
+			batched[v.CVE] = len(batch)
+			batch = append(batch, *v)
+			if len(batch) >= writeBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	log.Printf("Successfully fetched %d package vulnerabilities! (matched: %d, duplicates: %d, not scored yet: %d)",
+		result.Fetched,
+		result.Matched,
+		result.Skipped,
+		result.Unscored,
+	)
+
+	return nil
+}
+
+func mergePackageMatches(dst, src *db.Vulnerability) {
+	for _, productID := range src.ProductIDs {
+		if !slices.Contains(dst.ProductIDs, productID) {
+			dst.ProductIDs = append(dst.ProductIDs, productID)
+		}
+	}
+	dst.PackageMatches = append(dst.PackageMatches, src.PackageMatches...)
+
+	for i, productID := range src.NotAffectedProductIDs {
+		if !slices.Contains(dst.NotAffectedProductIDs, productID) {
+			dst.NotAffectedProductIDs = append(dst.NotAffectedProductIDs, productID)
+			dst.NotAffected = append(dst.NotAffected, src.NotAffected[i])
+		}
+	}
+
+	if dst.GHSA == nil {
+		dst.GHSA = src.GHSA
+	}
+}
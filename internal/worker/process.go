@@ -1,9 +1,11 @@
 package worker
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"maps"
 	"math"
+	"slices"
 	"time"
 
 	"github.com/nexryai/eleos/internal/db"
@@ -11,209 +13,321 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
-func toPtr(v int32) *int32 { 
-    return &v 
+func toPtr(v int32) *int32 {
+	return &v
 }
 
-func fetchNewVulnerabilities() (*[]nvd.VulnerabilityItem, error) {
-    // last 30 minutes
-    start := time.Now().Add(-30 * time.Minute)
-    end := time.Now()
+// scoreToPtr はCVSSの基本値 (0.0-10.0) を10倍した整数として返します
+func scoreToPtr(base float64) *int32 {
+	return toPtr(int32(math.Round(base * 10)))
+}
+
+// matchResult は設定の評価に使う三値論理 (false < unknown < true) です。
+// プラットフォームなどの前提条件を表すCPE (vulnerable: false) は、監視対象の製品に該当しない限り
+// 実際の環境で満たされるかを判断できないため unknown として扱います。
+type matchResult int
 
-	log.Printf("Fetching vulnerabilities modified between %s and %s\n",
-		start.Format(time.RFC3339),
-		end.Format(time.RFC3339),
-	)
+const (
+	matchFalse matchResult = iota
+	matchUnknown
+	matchTrue
+)
+
+func (r matchResult) not() matchResult {
+	return matchTrue - r
+}
 
-	vulnerabilities, err := nvd.FetchVulnerabilities(start, end)
-	if err != nil {
-		log.Printf("Error fetching vulnerabilities: %v\n", err)
-		return nil, fmt.Errorf("error fetching vulnerabilities: %w", err)
+// combine は AND なら最小値、OR なら最大値を取ります (クリーネの三値論理)
+func combine(isAndOperator bool, a, b matchResult) matchResult {
+	if isAndOperator {
+		return min(a, b)
 	}
+	return max(a, b)
+}
 
-	if vulnerabilities == nil || len(*vulnerabilities) == 0 {
-		log.Printf("No vulnerabilities found in the specified date range.")
-		return nil, nil
+func (r matchResult) String() string {
+	switch r {
+	case matchTrue:
+		return "TRUE"
+	case matchUnknown:
+		return "UNKNOWN"
+	default:
+		return "FALSE"
+	}
+}
+
+// cpeEvaluation は CPEMatch 1件の評価結果です
+type cpeEvaluation struct {
+	match nvd.CPEMatch
+	// rule は criteria に該当した製品のCPEパターンです (該当しない場合は空)
+	rule string
+	// versionMatched は運用中のバージョンがCPEのバージョンの範囲に含まれるかどうかです
+	versionMatched bool
+	result         matchResult
+}
+
+// nodeEvaluation はNodeの評価結果です
+type nodeEvaluation struct {
+	node   nvd.Node
+	cpes   []cpeEvaluation
+	result matchResult
+	// vulnerableHit は製品に該当する脆弱なCPEによってNodeが偽でなくなったかどうかです
+	vulnerableHit bool
+}
+
+// configurationEvaluation は設定の評価結果です
+type configurationEvaluation struct {
+	configuration nvd.Configuration
+	nodes         []nodeEvaluation
+	result        matchResult
+	// vulnerableHit は製品に該当する脆弱なCPEが評価に寄与したかどうかです
+	vulnerableHit bool
+}
+
+// matched は設定が偽でなく、かつ製品が脆弱なCPEとして含まれているかどうかを返します
+// (前提条件のCPEにしか該当しない場合は、その製品上で動く別の製品の脆弱性)
+func (e configurationEvaluation) matched() bool {
+	return e.result != matchFalse && e.vulnerableHit
+}
+
+// evaluateProduct は製品をCVEの全ての設定に対して評価します。
+// criteria が製品に該当するかどうかは rules で判定します。
+func evaluateProduct(product Product, rules ruleLookup, configurations []nvd.Configuration) []configurationEvaluation {
+	evaluations := make([]configurationEvaluation, 0, len(configurations))
+	for _, cfg := range configurations {
+		evaluations = append(evaluations, evaluateConfiguration(product, rules, cfg))
+	}
+	return evaluations
+}
+
+// explainProductMatch は製品がCVEにマッチした理由を返します。
+// マッチしない場合は空のスライスを返します。
+func explainProductMatch(productID bson.ObjectID, evaluations []configurationEvaluation) []db.MatchExplanation {
+	var explanations []db.MatchExplanation
+
+	// 1つでもマッチするConfigurationがあればマッチとする
+	for cfgIndex, cfg := range evaluations {
+		if !cfg.matched() {
+			continue
+		}
+
+		for nodeIndex, node := range cfg.nodes {
+			if !node.vulnerableHit {
+				continue
+			}
+
+			for _, cpe := range node.cpes {
+				if cpe.result != matchTrue || !cpe.match.Vulnerable {
+					continue
+				}
+
+				explanations = append(explanations, db.MatchExplanation{
+					ProductID:             productID,
+					Rule:                  cpe.rule,
+					Configuration:         cfgIndex,
+					Node:                  nodeIndex,
+					Criteria:              cpe.match.Criteria,
+					MatchCriteriaID:       cpe.match.MatchCriteriaID,
+					VersionStartIncluding: cpe.match.VersionStartIncluding,
+					VersionStartExcluding: cpe.match.VersionStartExcluding,
+					VersionEndIncluding:   cpe.match.VersionEndIncluding,
+					VersionEndExcluding:   cpe.match.VersionEndExcluding,
+				})
+			}
+		}
+	}
+
+	return explanations
+}
+
+// evaluateConfiguration は設定を評価します
+func evaluateConfiguration(product Product, rules ruleLookup, cfg nvd.Configuration) configurationEvaluation {
+	evaluation := configurationEvaluation{configuration: cfg}
+
+	if len(cfg.Nodes) == 0 {
+		// Nodeがない設定は無効 (マッチしない)
+		evaluation.result = matchFalse
+		return evaluation
+	}
+
+	isAndOperator := cfg.Operator == "AND"
+	if isAndOperator {
+		evaluation.result = matchTrue
+	}
+
+	for _, node := range cfg.Nodes {
+		nodeEvaluation := evaluateNode(product, rules, node)
+
+		evaluation.nodes = append(evaluation.nodes, nodeEvaluation)
+		evaluation.result = combine(isAndOperator, evaluation.result, nodeEvaluation.result)
+		evaluation.vulnerableHit = evaluation.vulnerableHit || nodeEvaluation.vulnerableHit
+	}
+
+	return evaluation
+}
+
+// evaluateNode はNodeを評価します
+func evaluateNode(product Product, rules ruleLookup, node nvd.Node) nodeEvaluation {
+	evaluation := nodeEvaluation{node: node}
+
+	if len(node.CPEMatch) == 0 {
+		// CPEMatchがないNodeは無効 (マッチしない)
+		evaluation.result = matchFalse
+		return evaluation
+	}
+
+	isAndOperator := node.Operator == "AND"
+	result := matchFalse
+	if isAndOperator {
+		result = matchTrue
+	}
+	vulnerableHit := false
+
+	for _, cpe := range node.CPEMatch {
+		// criteria 文字列が製品に該当し、かつ運用中のバージョンが
+		// CPEのバージョンや範囲 (versionStart* / versionEnd*) に含まれるかを判定する
+		rule, criteriaMatches := rules(cpe.Criteria)
+		cpeEvaluation := cpeEvaluation{
+			match:          cpe,
+			rule:           rule,
+			versionMatched: criteriaMatches && matchesDeployedVersion(product, cpe.VersionRange()),
+		}
+
+		switch {
+		case cpeEvaluation.versionMatched:
+			cpeEvaluation.result = matchTrue
+			vulnerableHit = vulnerableHit || cpe.Vulnerable
+		case cpe.Vulnerable:
+			// 脆弱なCPEが製品に該当しない
+			cpeEvaluation.result = matchFalse
+		default:
+			// 製品に該当しない前提条件は判断できない
+			cpeEvaluation.result = matchUnknown
+		}
+
+		evaluation.cpes = append(evaluation.cpes, cpeEvaluation)
+		result = combine(isAndOperator, result, cpeEvaluation.result)
 	}
 
-	log.Printf("Successfully fetched %d total vulnerabilities!", len(*vulnerabilities))
-
-	return vulnerabilities, nil
-}
-
-func checkProductMatch(product Product, configurations []nvd.Configuration) bool {
-    // CVEに設定が全くない場合は、マッチしない
-    if len(configurations) == 0 {
-        return false
-    }
-    
-    // 1つでもマッチするConfigurationがあれば true
-    for _, cfg := range configurations {
-        if evaluateConfiguration(product, cfg) {
-            return true
-        }
-    }
-    
-    // どのConfigurationもマッチしなかった
-    return false
-}
-
-func evaluateConfiguration(product Product, cfg nvd.Configuration) bool {
-    if len(cfg.Nodes) == 0 {
-        // Nodeがない設定は無効 (マッチしない)
-        return false
-    }
-
-    isAndOperator := cfg.Operator == "AND"
-
-    for _, node := range cfg.Nodes {
-        nodeMatches := evaluateNode(product, node)
-
-        if isAndOperator {
-            // AND の場合: 1つでも false なら、このConfigurationは false
-            if !nodeMatches {
-                return false
-            }
-        } else {
-            // OR の場合: 1つでも true なら、このConfigurationは true
-            if nodeMatches {
-                return true
-            }
-        }
-    }
-
-    // ループが完了した場合:
-    // AND の場合: 全てのnodeが true だった
-    // OR  の場合: 全てのnodeが false だった
-    return isAndOperator
-}
-
-func evaluateNode(product Product, node nvd.Node) bool {
-    if len(node.CPEMatch) == 0 {
-        // CPEMatchがないNodeは無効 (マッチしない)
-        // (注: 'negate' を考慮する場合はここのロジックが変わります)
-        return false
-    }
-
-    isAndOperator := node.Operator == "AND"
-
-    for _, cpe := range node.CPEMatch {
-        // product.CheckCPE のロジックは、
-        // 渡された criteria 文字列が製品に該当するかを
-        // 判断するものと仮定します。
-        cpeMatches := product.CheckCPE(cpe.Criteria)
-
-        if isAndOperator {
-            // AND の場合: 1つでも false なら、このNodeは false
-            if !cpeMatches {
-                return false
-            }
-        } else {
-            // OR の場合: 1つでも true なら、このNodeは true
-            if cpeMatches {
-                return true
-            }
-        }
-    }
-
-    // ループが完了した場合:
-    // AND の場合: 全てのcpeが true だった
-    // OR  の場合: 全てのcpeが false だった
-    return isAndOperator
-}
-
-func processVulnerabilities(vulnerabilities *[]nvd.VulnerabilityItem) (*[]db.Vulnerability, error) {
-    fmt.Println("\n--- Displaying results ---")
-
-    dbVulnerabilities := []db.Vulnerability{}
-
-
-    for _, item := range *vulnerabilities {
-        var matchedProductUUID string
-
-        // ProductLoop: 監視対象の各製品をチェック
-        for _, product := range products {
-            // この製品がCVEのいずれかの設定にマッチするかどうかを評価
-            if checkProductMatch(product, item.CVE.Configurations) {
-                matchedProductUUID = product.UUID()
-                
-                // このCVEに対してマッチする製品が見つかったため、
-                // 他の製品をチェックする必要はない
-                break
-            }
-        }
-
-        // このCVEにマッチする製品がなかった場合は、次の脆弱性へ
-        if matchedProductUUID == "" {
-            continue
-        }
-
-        // マッチした場合
-        fmt.Printf("\nCVE ID: %s\n", item.CVE.ID)
-        fmt.Printf("  Matched Product UUID: %s\n", matchedProductUUID)
-
-        // 英語の説明を探して表示
-        var enDesc string
-        for _, desc := range item.CVE.Descriptions {
-            if desc.Lang == "en" {
-                enDesc = desc.Value
-                break
-            }
-        }
-
-        if enDesc != "" {
-            // if len(enDesc) > 100 {
-            // 	fmt.Printf("  Description (en): %s...\n", enDesc[:100])
-            // } else {
-            // 	fmt.Printf("  Description (en): %s\n", enDesc)
-            // }
-        } else {
-            fmt.Println("  No English description found.")
-        }
-
-        var cvss40 int32 = 0
-        var cvss31 int32 = 0
-        var cvss30 int32 = 0
-        var cvss20 int32 = 0
-
-        if len(item.CVE.Metrics.CVSSMetricV40) > 0 {
-            base := item.CVE.Metrics.CVSSMetricV40[0].CVSSData.BaseScore
-            cvss40 = int32(math.Round(base * 10))
-        }
-
-        if len(item.CVE.Metrics.CVSSMetricV31) > 0 {
-            base := item.CVE.Metrics.CVSSMetricV31[0].CVSSData.BaseScore
-            cvss31 = int32(math.Round(base * 10))
-        }
-
-        if len(item.CVE.Metrics.CVSSMetricV2) > 0 {
-            base := item.CVE.Metrics.CVSSMetricV2[0].CVSSData.BaseScore
-            cvss20 = int32(math.Round(base * 10))
-        }
-
-        if (cvss40 == 0 && cvss31 == 0 && cvss30 == 0 && cvss20 == 0) {
-            // どのスコアも0なら未解析の脆弱性なので飛ばす
-            continue
-        }
-
-		productObjectID, err := bson.ObjectIDFromHex(matchedProductUUID)
+	if node.Negate {
+		// 否定されたNodeは「その製品ではない」ことを表すため、脆弱性の帰属には使わない
+		evaluation.result = result.not()
+		return evaluation
+	}
+
+	evaluation.result = result
+	evaluation.vulnerableHit = vulnerableHit && result != matchFalse
+	return evaluation
+}
+
+// errNotScored は製品にマッチしたものの、CVSSスコアがまだ付与されていないことを表します。
+// スコアが付与された時点で改めて取り込むため、暫定的なマッチは残しておきます。
+var errNotScored = errors.New("matched but not scored yet")
+
+// processVulnerability は脆弱性を監視対象の製品と照合し、DBに保存する形式に変換します。
+// どの製品にもマッチしない場合は nil を、マッチしたがスコアがまだない場合は errNotScored を返します。
+// 設定 (CPE) がまだないCVEは、暫定的な照合条件で照合します。
+func processVulnerability(item nvd.VulnerabilityItem) (*db.Vulnerability, error) {
+	if len(item.CVE.Configurations) == 0 {
+		return processTentativeVulnerability(item)
+	}
+
+	var productIDs []bson.ObjectID
+	var matches []db.MatchExplanation
+
+	// 索引でcriteriaに該当した製品だけを評価する
+	// (ドライバなど複数の製品に影響するCVEもあるため、該当した全ての製品を評価する)
+	rules := productMatcher.match(item.CVE.Configurations)
+
+	// ProductLoop: 候補の各製品をチェック
+	for _, i := range slices.Sorted(maps.Keys(rules)) {
+		product := productMatcher.products[i]
+		productObjectID, err := bson.ObjectIDFromHex(product.UUID())
 		if err != nil {
-            return nil, fmt.Errorf("invalid object id")
-        }
-
-        dbVuln := db.Vulnerability{
-            CVE:         item.CVE.ID,
-            PublishedAt: item.CVE.Published.Time,
-            Description: enDesc,
-            CVSS40:      toPtr(cvss40),
-            CVSS31:      toPtr(cvss31),
-            CVSS30:      toPtr(cvss30),
-            CVSS20:      toPtr(cvss20),
-            ProductID:   productObjectID,
-        }
-
-        dbVulnerabilities = append(dbVulnerabilities, dbVuln)
-    }
-
-    return &dbVulnerabilities, nil
+			return nil, fmt.Errorf("invalid object id %q: %w", product.UUID(), err)
+		}
+
+		// この製品がCVEのいずれかの設定にマッチするかどうかを評価
+		evaluations := evaluateProduct(product, rules.lookup(i), item.CVE.Configurations)
+		explanations := explainProductMatch(productObjectID, evaluations)
+		if len(explanations) == 0 {
+			continue
+		}
+
+		productIDs = append(productIDs, productObjectID)
+		matches = append(matches, explanations...)
+	}
+
+	// このCVEにマッチする製品がなかった場合は、次の脆弱性へ
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	// マッチした場合
+	fmt.Printf("\nCVE ID: %s\n", item.CVE.ID)
+	for _, productID := range productIDs {
+		fmt.Printf("  Matched Product UUID: %s\n", productID.Hex())
+	}
+
+	v := newVulnerability(item)
+	if !v.IsScored() {
+		// どのスコアもなければ未解析の脆弱性なので、今回は保存しない
+		return nil, errNotScored
+	}
+
+	v.ProductIDs = productIDs
+	v.Matches = matches
+	applySuppressions(v, time.Now())
+	return v, nil
+}
+
+// englishDescription は英語の説明を返します。ない場合は空文字列を返します。
+func englishDescription(descriptions []nvd.Description) string {
+	for _, desc := range descriptions {
+		if desc.Lang == "en" {
+			return desc.Value
+		}
+	}
+	return ""
+}
+
+// newVulnerability は製品との照合結果以外の項目 (説明・スコア・CWE・参考情報など) を埋めた脆弱性を作ります
+func newVulnerability(item nvd.VulnerabilityItem) *db.Vulnerability {
+	// 英語の説明を探して表示
+	enDesc := englishDescription(item.CVE.Descriptions)
+	if enDesc == "" {
+		fmt.Println("  No English description found.")
+	}
+
+	// スコアが存在しないバージョンは 0 ではなく nil のまま保存する
+	scores := item.CVE.Metrics.Scores()
+
+	return &db.Vulnerability{
+		CVE:          item.CVE.ID,
+		PublishedAt:  item.CVE.Published.Time,
+		LastModified: item.CVE.LastModified.Time,
+		Description:  enDesc,
+		CWEs:         item.CVE.CWEs(),
+		CVSS40:       scorePolicy.selectedScore(scores, "4.0"),
+		CVSS31:       scorePolicy.selectedScore(scores, "3.1"),
+		CVSS30:       scorePolicy.selectedScore(scores, "3.0"),
+		CVSS20:       scorePolicy.selectedScore(scores, "2.0"),
+		CVSSScores:   scorePolicy.toDBScores(scores),
+		Severity:     scorePolicy.selectedSeverity(scores),
+		VulnStatus:   item.CVE.VulnStatus,
+		References:   toDBReferences(item.CVE.References),
+	}
+}
+
+// toDBReferences は参考情報のURLをDBに保存する形式に変換します
+func toDBReferences(references []nvd.Reference) []db.Reference {
+	dbReferences := make([]db.Reference, 0, len(references))
+	for _, reference := range references {
+		dbReferences = append(dbReferences, db.Reference{
+			URL:    reference.URL,
+			Source: reference.Source,
+			Tags:   reference.Tags,
+		})
+	}
+	return dbReferences
 }
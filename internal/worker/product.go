@@ -1,13 +1,242 @@
 package worker
 
-import "github.com/nexryai/eleos/internal/product"
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/nexryai/eleos/internal/cpe"
+	"github.com/nexryai/eleos/internal/db"
+	"github.com/nexryai/eleos/internal/nvd"
+	"github.com/nexryai/eleos/internal/product"
+	"github.com/nexryai/eleos/internal/purl"
+	"github.com/nexryai/eleos/internal/version"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
 
 type Product interface {
 	UUID() string
-	CheckCPE(string) bool
+	// Patterns は製品に該当するCPE名のパターンを返します
+	Patterns() []cpe.Name
+	// MatchName は解析済みのCPE名に該当した製品のCPEパターンを返します
+	MatchName(name cpe.Name) (string, bool)
+	// MatchTentative は設定がまだないCVEの sourceIdentifier と説明文が暫定的な照合条件に該当するかどうかを返します
+	MatchTentative(sourceIdentifier, description string) (string, bool)
+	// PackageURLs は製品に該当するパッケージのURLを返します
+	PackageURLs() []purl.PackageURL
+	// MatchPackage はパッケージと影響を受けるバージョンの範囲が製品に該当するかどうかを返します
+	MatchPackage(pkg purl.PackageURL, affected []version.Range) (string, bool)
+	// Suppression は脆弱性に適用される抑制ルールを返します (適用されない場合は nil)
+	Suppression(cveID string, cwes, criteria []string, now time.Time) *product.Suppression
+	HasSuppressions() bool
+	// DeployedVersions は運用中のバージョン (またはその範囲) を返します。空の場合は全てのバージョンを対象とします。
+	DeployedVersions() []version.Range
+	// CompareVersions はその製品のバージョン体系に従って2つのバージョンを比較します
+	CompareVersions(a, b string) int
+}
+
+// products は実行のたびに loadProducts で読み込まれる監視対象の製品です
+var products []Product
+
+// loadProducts は products コレクションに保存された照合条件から監視対象の製品を読み込みます。
+// 照合条件を持つ製品がまだ1つもない場合は、PRODUCTS_FILE で指定された定義
+// (指定されていない場合は組み込みの定義) をコレクションに登録してから読み込みます。
+func loadProducts(ctx context.Context, database *mongo.Database) error {
+	documents, err := db.ListProducts(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(documents, func(p db.Product) bool { return p.Matching != nil }) {
+		if err := seedProducts(ctx, database); err != nil {
+			return err
+		}
+
+		if documents, err = db.ListProducts(ctx, database); err != nil {
+			return err
+		}
+	} else if path := getEnv("PRODUCTS_FILE", ""); path != "" {
+		// 登録済みの照合条件 (管理画面での変更を含む) を優先するため、設定ファイルは自動では反映しない
+		log.Printf("Warning: PRODUCTS_FILE (%s) is not applied because products already have matching rules. "+
+			"Run the sync-products command to overwrite them with the file.", path)
+	}
+
+	return useProducts(documents)
+}
+
+// useProducts は products コレクションのドキュメントのうち、照合条件が有効な製品を監視対象にします
+func useProducts(documents []db.Product) error {
+	products = make([]Product, 0, len(documents))
+	for _, document := range documents {
+		if document.Matching == nil || !document.Matching.Enabled {
+			continue
+		}
+
+		definition, err := definitionFromDocument(document)
+		if err != nil {
+			return fmt.Errorf("invalid matching rules for product %s: %w", document.Name, err)
+		}
+		products = append(products, definition)
+	}
+
+	productMatcher = newMatcher(products)
+
+	log.Printf("Loaded %d enabled products.", len(products))
+	return nil
+}
+
+// productDefinitions は PRODUCTS_FILE で指定された製品定義を読み込みます。
+// 指定されていない場合は組み込みの定義を返します。
+func productDefinitions() ([]*product.Definition, error) {
+	path := getEnv("PRODUCTS_FILE", "")
+	if path == "" {
+		return product.Defaults(), nil
+	}
+
+	definitions, err := product.LoadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load products from %s: %w", path, err)
+	}
+	return definitions, nil
+}
+
+// seedProducts は設定ファイルまたは組み込みの製品定義をコレクションに登録します
+func seedProducts(ctx context.Context, database *mongo.Database) error {
+	definitions, err := productDefinitions()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Seeding matching rules for %d products...", len(definitions))
+	for _, definition := range definitions {
+		id, err := bson.ObjectIDFromHex(definition.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := db.SeedProductMatching(ctx, database, id, definition.Name, productMatching(definition)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExecuteSyncProducts は PRODUCTS_FILE (指定されていない場合は組み込みの定義) の照合条件で、
+// 登録済みの製品の照合条件を上書きします。ファイルにない製品は変更しません。
+// 登録済みの照合条件が不正な場合も上書きできるよう、読み込む前に同期します。
+func ExecuteSyncProducts(ctx context.Context) error {
+	database, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	definitions, err := productDefinitions()
+	if err != nil {
+		return err
+	}
+
+	for _, definition := range definitions {
+		id, err := bson.ObjectIDFromHex(definition.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := db.SyncProductMatching(ctx, database, id, definition.Name, productMatching(definition)); err != nil {
+			return err
+		}
+	}
+	log.Printf("Synced matching rules for %d products.", len(definitions))
+
+	// 変更後の照合条件と抑制ルールを反映する
+	documents, err := db.ListProducts(ctx, database)
+	if err != nil {
+		return err
+	}
+	if err := useProducts(documents); err != nil {
+		return err
+	}
+	return reconcileSuppressions(ctx, database)
+}
+
+// matchesDeployedVersion は運用中のいずれかのバージョンが範囲に含まれるかどうかを返します
+func matchesDeployedVersion(product Product, versionRange nvd.VersionRange) bool {
+	versions := product.DeployedVersions()
+	if len(versions) == 0 || versionRange.IsAny() {
+		return true
+	}
+
+	for _, v := range versions {
+		if versionRange.Overlaps(v, product.CompareVersions) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// definitionFromDocument は products コレクションのドキュメントに保存された照合条件から定義を作ります
+func definitionFromDocument(p db.Product) (*product.Definition, error) {
+	if p.Matching == nil {
+		return nil, fmt.Errorf("product %s has no matching rules", p.ID.Hex())
+	}
+
+	d := &product.Definition{
+		Name:          p.Name,
+		ID:            p.ID.Hex(),
+		CPEs:          p.Matching.CPEs,
+		Exclude:       p.Matching.Exclude,
+		VersionScheme: p.Matching.VersionScheme,
+		Versions:      p.Matching.Versions,
+	}
+	if t := p.Matching.Tentative; t != nil {
+		d.Tentative = &product.Tentative{Sources: t.Sources, Keywords: t.Keywords, Patterns: t.Patterns}
+	}
+	for _, pkg := range p.Matching.Packages {
+		d.Packages = append(d.Packages, &product.Package{Purl: pkg.Purl, VersionScheme: pkg.VersionScheme, Versions: pkg.Versions})
+	}
+	for _, s := range p.Matching.Suppressions {
+		d.Suppressions = append(d.Suppressions, &product.Suppression{
+			CVE:           s.CVE,
+			CWE:           s.CWE,
+			CPE:           s.CPE,
+			ExpiresAt:     s.ExpiresAt,
+			Justification: s.Justification,
+		})
+	}
+	if err := d.Compile(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
 }
 
-var products = []Product{
-	&product.Linux{},
-	&product.Windows{},
+// productMatching は定義をドキュメントに保存する形式の照合条件に変換します
+func productMatching(d *product.Definition) db.ProductMatching {
+	matching := db.ProductMatching{
+		Enabled:       true,
+		CPEs:          d.CPEs,
+		Exclude:       d.Exclude,
+		VersionScheme: d.VersionScheme,
+		Versions:      d.Versions,
+	}
+	if t := d.Tentative; t != nil {
+		matching.Tentative = &db.TentativeMatching{Sources: t.Sources, Keywords: t.Keywords, Patterns: t.Patterns}
+	}
+	for _, p := range d.Packages {
+		matching.Packages = append(matching.Packages, db.PackageMatching{Purl: p.Purl, VersionScheme: p.VersionScheme, Versions: p.Versions})
+	}
+	for _, s := range d.Suppressions {
+		matching.Suppressions = append(matching.Suppressions, db.Suppression{
+			CVE:           s.CVE,
+			CWE:           s.CWE,
+			CPE:           s.CPE,
+			ExpiresAt:     s.ExpiresAt,
+			Justification: s.Justification,
+		})
+	}
+	return matching
 }
@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/nexryai/eleos/internal/db"
+	"github.com/nexryai/eleos/internal/nvd"
+)
+
+// ScorePolicy は同じバージョンのCVSSスコアが複数の提供元から得られた場合に、どれを採用するかを表します
+type ScorePolicy string
+
+const (
+	// PreferPrimary はNVD自身が付与した Primary のスコアを優先します
+	PreferPrimary ScorePolicy = "primary"
+	// PreferCNA はCNAが付与した Secondary のスコアを優先します
+	PreferCNA ScorePolicy = "cna"
+	// PreferHighest は提供元に関わらず最も高いスコアを採用します
+	PreferHighest ScorePolicy = "highest"
+)
+
+var scorePolicy = ScorePolicy(getEnv("CVSS_SOURCE_POLICY", string(PreferPrimary)))
+
+func (p ScorePolicy) validate() error {
+	switch p {
+	case PreferPrimary, PreferCNA, PreferHighest:
+		return nil
+	default:
+		return fmt.Errorf("unknown CVSS source policy: %q (expected primary, cna or highest)", p)
+	}
+}
+
+// selectScore はポリシーに従い、指定したバージョンのスコアを1つ選びます。
+// 該当するバージョンのスコアがない場合は nil を返します。
+func (p ScorePolicy) selectScore(scores []nvd.Score, version string) *nvd.Score {
+	var candidates []nvd.Score
+	for _, score := range scores {
+		if score.Version == version {
+			candidates = append(candidates, score)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch p {
+	case PreferHighest:
+		best := candidates[0]
+		for _, score := range candidates[1:] {
+			if score.BaseScore > best.BaseScore {
+				best = score
+			}
+		}
+		return &best
+	case PreferCNA:
+		return preferType(candidates, "Secondary")
+	default:
+		return preferType(candidates, "Primary")
+	}
+}
+
+// preferType は指定した種類のスコアを返し、なければ最初のスコアを返します
+func preferType(candidates []nvd.Score, scoreType string) *nvd.Score {
+	for _, score := range candidates {
+		if score.Type == scoreType {
+			return &score
+		}
+	}
+	return &candidates[0]
+}
+
+// selectedScore はポリシーで選んだスコアを10倍した整数で返します
+func (p ScorePolicy) selectedScore(scores []nvd.Score, version string) *int32 {
+	score := p.selectScore(scores, version)
+	if score == nil {
+		return nil
+	}
+	return scoreToPtr(score.BaseScore)
+}
+
+// scoreVersions はCVSSのバージョンを新しい順に並べたものです
+var scoreVersions = []string{"4.0", "3.1", "3.0", "2.0"}
+
+// selectedSeverity はポリシーで選んだスコアのうち、最も新しいバージョンの深刻度を返します
+func (p ScorePolicy) selectedSeverity(scores []nvd.Score) string {
+	for _, version := range scoreVersions {
+		if score := p.selectScore(scores, version); score != nil {
+			return score.BaseSeverity
+		}
+	}
+	return ""
+}
+
+// toDBScores は全ての提供元のスコアをDBに保存する形式に変換し、ポリシーで選んだスコアに印を付けます
+func (p ScorePolicy) toDBScores(scores []nvd.Score) []db.CVSSScore {
+	var selected []nvd.Score
+	for _, version := range scoreVersions {
+		if score := p.selectScore(scores, version); score != nil {
+			selected = append(selected, *score)
+		}
+	}
+
+	dbScores := make([]db.CVSSScore, 0, len(scores))
+	for _, score := range scores {
+		dbScores = append(dbScores, db.CVSSScore{
+			Version:      score.Version,
+			Source:       score.Source,
+			Type:         score.Type,
+			Score:        *scoreToPtr(score.BaseScore),
+			VectorString: score.VectorString,
+			BaseSeverity: score.BaseSeverity,
+			Selected:     slices.Contains(selected, score),
+		})
+	}
+	return dbScores
+}
@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nexryai/eleos/internal/db"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// applySuppressions は製品ごとの抑制ルールを適用し、該当した製品を ProductIDs から NotAffected に移します。
+// 移された製品には recentVulnerabilities が追加されませんが、監査のために脆弱性自体は記録されます。
+func applySuppressions(v *db.Vulnerability, now time.Time) {
+	affected := v.ProductIDs[:0:0]
+
+	for _, productID := range v.ProductIDs {
+		product, ok := productMatcher.product(productID.Hex())
+		if !ok {
+			affected = append(affected, productID)
+			continue
+		}
+
+		suppression := product.Suppression(v.CVE, v.CWEs, v.Criteria(productID), now)
+		if suppression == nil {
+			affected = append(affected, productID)
+			continue
+		}
+
+		fmt.Printf("  Suppressed Product UUID: %s (%s)\n", productID.Hex(), suppression)
+		v.NotAffectedProductIDs = append(v.NotAffectedProductIDs, productID)
+		v.NotAffected = append(v.NotAffected, db.NotAffected{
+			ProductID:     productID,
+			Rule:          suppression.String(),
+			Justification: suppression.Justification,
+			SuppressedAt:  now,
+		})
+	}
+
+	v.ProductIDs = affected
+}
+
+// reconcileSuppressions は抑制ルールの追加・削除・期限切れを既に登録された脆弱性に反映します
+func reconcileSuppressions(ctx context.Context, database *mongo.Database) error {
+	now := time.Now()
+
+	for _, product := range productMatcher.products {
+		productID, err := bson.ObjectIDFromHex(product.UUID())
+		if err != nil {
+			return fmt.Errorf("invalid object id %q: %w", product.UUID(), err)
+		}
+
+		suppress := make(map[string]db.NotAffected)
+		if product.HasSuppressions() {
+			vulns, err := db.ListAffectedVulnerabilities(ctx, database, productID)
+			if err != nil {
+				return err
+			}
+
+			for _, v := range vulns {
+				suppression := product.Suppression(v.CVE, v.CWEs, v.Criteria(productID), now)
+				if suppression == nil {
+					continue
+				}
+
+				suppress[v.CVE] = db.NotAffected{
+					ProductID:     productID,
+					Rule:          suppression.String(),
+					Justification: suppression.Justification,
+					SuppressedAt:  now,
+				}
+			}
+		}
+
+		// ルールが削除されたか期限が切れたマッチは元に戻す
+		var restore []string
+		suppressed, err := db.ListNotAffectedVulnerabilities(ctx, database, productID)
+		if err != nil {
+			return err
+		}
+		for _, v := range suppressed {
+			if product.Suppression(v.CVE, v.CWEs, v.Criteria(productID), now) == nil {
+				restore = append(restore, v.CVE)
+			}
+		}
+
+		if err := db.UpdateSuppressions(ctx, database, productID, suppress, restore, runID); err != nil {
+			return err
+		}
+	}
+
+	log.Print("Suppression rules reconciled.")
+	return nil
+}
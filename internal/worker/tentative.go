@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"fmt"
+
+	"github.com/nexryai/eleos/internal/db"
+	"github.com/nexryai/eleos/internal/nvd"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// processTentativeVulnerability は設定 (CPE) がまだない "Awaiting Analysis" などのCVEを、
+// 製品ごとの暫定的な照合条件 (CNAの識別子・キーワード・正規表現) で照合します。
+// 該当した製品は確定したマッチとは別に記録され、設定が追加された時点で改めて照合されます。
+func processTentativeVulnerability(item nvd.VulnerabilityItem) (*db.Vulnerability, error) {
+	description := englishDescription(item.CVE.Descriptions)
+
+	var productIDs []bson.ObjectID
+	var matches []db.TentativeMatch
+
+	for _, product := range productMatcher.products {
+		rule, ok := product.MatchTentative(item.CVE.SourceIdentifier, description)
+		if !ok {
+			continue
+		}
+
+		productObjectID, err := bson.ObjectIDFromHex(product.UUID())
+		if err != nil {
+			return nil, fmt.Errorf("invalid object id %q: %w", product.UUID(), err)
+		}
+
+		productIDs = append(productIDs, productObjectID)
+		matches = append(matches, db.TentativeMatch{ProductID: productObjectID, Rule: rule})
+	}
+
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	fmt.Printf("\nCVE ID: %s (tentative)\n", item.CVE.ID)
+	for _, match := range matches {
+		fmt.Printf("  Tentatively Matched Product UUID: %s (%s)\n", match.ProductID.Hex(), match.Rule)
+	}
+
+	// 解析前のCVEにはスコアがないことが多いため、スコアがなくても記録する
+	v := newVulnerability(item)
+	v.TentativeProductIDs = productIDs
+	v.TentativeMatches = matches
+	return v, nil
+}
@@ -1,15 +1,169 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/nexryai/eleos/internal/nvd"
+	"github.com/nexryai/eleos/internal/osv"
 	"github.com/nexryai/eleos/internal/worker"
 )
 
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC3339)", value)
+}
+
+// userAgentEnv はAPIごとの User-Agent の環境変数を返します。設定されていない場合は共通の USER_AGENT を返します。
+func userAgentEnv(key string) string {
+	if userAgent := os.Getenv(key); userAgent != "" {
+		return userAgent
+	}
+	return os.Getenv("USER_AGENT")
+}
+
+// newNVDClient は環境変数の設定からNVDクライアントを生成します
+func newNVDClient() *nvd.Client {
+	var opts []nvd.Option
+
+	if baseURL := os.Getenv("NVD_BASE_URL"); baseURL != "" {
+		opts = append(opts, nvd.WithBaseURL(baseURL))
+	}
+	if userAgent := userAgentEnv("NVD_USER_AGENT"); userAgent != "" {
+		opts = append(opts, nvd.WithUserAgent(userAgent))
+	}
+	if pageSize, err := strconv.Atoi(os.Getenv("NVD_PAGE_SIZE")); err == nil {
+		opts = append(opts, nvd.WithPageSize(pageSize))
+	}
+
+	return nvd.NewClient(opts...)
+}
+
+// newOSVClient は環境変数の設定からOSVクライアントを生成します
+func newOSVClient() *osv.Client {
+	var opts []osv.Option
+
+	if baseURL := os.Getenv("OSV_BASE_URL"); baseURL != "" {
+		opts = append(opts, osv.WithBaseURL(baseURL))
+	}
+	if userAgent := userAgentEnv("OSV_USER_AGENT"); userAgent != "" {
+		opts = append(opts, osv.WithUserAgent(userAgent))
+	}
+
+	return osv.NewClient(opts...)
+}
+
+func runBackfill(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	from := fs.String("from", "", "start date of the backfill (YYYY-MM-DD or RFC3339)")
+	to := fs.String("to", "", "end date of the backfill (defaults to now)")
+	fs.Parse(args)
+
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+
+	start, err := parseDate(*from)
+	if err != nil {
+		return err
+	}
+
+	end := time.Now()
+	if *to != "" {
+		if end, err = parseDate(*to); err != nil {
+			return err
+		}
+	}
+
+	return worker.ExecuteBackfill(ctx, newNVDClient(), start, end)
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := fs.String("dir", ".", "directory containing nvdcve-2.0-*.json.gz and .meta files")
+	fs.Parse(args)
+
+	return worker.ExecuteImport(ctx, *dir)
+}
+
+func runExplain(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	cveID := fs.String("cve", "", "CVE ID to evaluate (e.g. CVE-2024-1234)")
+	productName := fs.String("product", "", "name or id of the product")
+	fs.Parse(args)
+
+	if *cveID == "" || *productName == "" {
+		return fmt.Errorf("-cve and -product are required")
+	}
+
+	return worker.ExecuteExplain(ctx, newNVDClient(), *cveID, *productName, os.Stdout)
+}
+
+func runAudit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	productName := fs.String("product", "", "name or id of the product")
+	fs.Parse(args)
+
+	if *productName == "" {
+		return fmt.Errorf("-product is required")
+	}
+
+	return worker.ExecuteAudit(ctx, *productName, os.Stdout)
+}
+
+func runHistory(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	cveID := fs.String("cve", "", "CVE ID to show the revisions of (e.g. CVE-2024-1234)")
+	fs.Parse(args)
+
+	if *cveID == "" {
+		return fmt.Errorf("-cve is required")
+	}
+
+	return worker.ExecuteHistory(ctx, *cveID, os.Stdout)
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return worker.ExecuteJob(ctx, newNVDClient(), newOSVClient())
+	}
+
+	switch args[0] {
+	case "run":
+		return worker.ExecuteJob(ctx, newNVDClient(), newOSVClient())
+	case "backfill":
+		return runBackfill(ctx, args[1:])
+	case "import":
+		return runImport(ctx, args[1:])
+	case "explain":
+		return runExplain(ctx, args[1:])
+	case "audit":
+		return runAudit(ctx, args[1:])
+	case "history":
+		return runHistory(ctx, args[1:])
+	case "sync-products":
+		return worker.ExecuteSyncProducts(ctx)
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
 func main() {
-	err := worker.ExecuteJob()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:])
 	if err != nil {
 		fmt.Println("Error executing job:", err)
 		os.Exit(1)
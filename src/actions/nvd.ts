@@ -26,6 +26,12 @@ const getLatestCvssScore = (metrics: Metrics): LatestCVSS | null => {
             severity: metrics.cvssMetricV31[0].cvssData.baseSeverity,
             version: "3.1",
         };
+    } else if (metrics.cvssMetricV30 && metrics.cvssMetricV30.length > 0) {
+        return {
+            score: metrics.cvssMetricV30[0].cvssData.baseScore,
+            severity: metrics.cvssMetricV30[0].cvssData.baseSeverity,
+            version: "3.0",
+        };
     } else if (metrics.cvssMetricV2 && metrics.cvssMetricV2.length > 0) {
         return {
             score: metrics.cvssMetricV2[0].cvssData.baseScore,
@@ -251,6 +251,7 @@ export type Cve = {
 export type Metrics = {
     cvssMetricV40?: CvssMetricV40[];
     cvssMetricV31?: CvssMetricV31[];
+    cvssMetricV30?: CvssMetricV30[];
     cvssMetricV2?: CvssMetricV2[];
 };
 
@@ -311,6 +312,16 @@ export type CvssDataV31 = {
     availabilityImpact: CvssImpact;
 };
 
+// CVSS Metric V3.0
+export type CvssMetricV30 = CvssMetricBase<CvssDataV30> & {
+    exploitabilityScore: number;
+    impactScore: number;
+};
+
+export type CvssDataV30 = Omit<CvssDataV31, "version"> & {
+    version: "3.0";
+};
+
 // CVSS Metric V2
 export type CvssMetricV2 = CvssMetricBase<CvssDataV2> & {
     baseSeverity: CvssSeverity; // V2の重大度は Low, Medium, High のみ
@@ -354,6 +365,10 @@ export type Node = {
 export type CpeMatch = {
     vulnerable: boolean;
     criteria: string;
+    versionStartIncluding?: string;
+    versionStartExcluding?: string;
+    versionEndIncluding?: string;
+    versionEndExcluding?: string;
     matchCriteriaId: string;
 };
 
@@ -362,3 +377,44 @@ export type Reference = {
     source: string;
     tags?: string[];
 };
+
+// --- DBに保存された脆弱性 (vulnerabilities コレクション) ---
+
+/** 提供元 (NVD / CNA) ごとのCVSSスコア */
+export type StoredCvssScore = {
+    version: "4.0" | "3.1" | "3.0" | "2.0";
+    source: string;
+    type: CveSourceType;
+    // 基本値 (0.0-10.0) を10倍した整数
+    score: number;
+    vectorString: string;
+    baseSeverity?: CvssSeverity;
+    // ポリシーによってそのバージョンのスコア (cvss40 など) として採用されたかどうか
+    selected?: boolean;
+};
+
+export type StoredVulnerability = {
+    id: string;
+    cve: string;
+    ghsa?: string;
+    createdAt: string;
+    updatedAt: string;
+    publishedAt: string;
+    // NVDで最後に更新された日時
+    lastModified?: string;
+    description: string;
+    // NVDの解析状況 (Received, Analyzed, Modified など)
+    vulnStatus?: string;
+    cwes?: string[];
+    // 採用されたスコアの基本値を10倍した整数
+    cvss40?: number;
+    cvss31?: number;
+    cvss30?: number;
+    cvss20?: number;
+    cvssScores?: StoredCvssScore[];
+    // 採用されたスコアのうち、最も新しいバージョンの深刻度
+    severity?: CvssSeverity;
+    // タグ (Patch, Exploit, Vendor Advisory など) はNVDが付与した分類
+    references?: Reference[];
+    productIds: string[];
+};